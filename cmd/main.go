@@ -12,6 +12,7 @@ import (
 	"github.com/go-co-op/gocron"
 	"github.com/twilio/twilio-go"
 	"github.com/willtowle1/parkn/internal/app"
+	"github.com/willtowle1/parkn/internal/common/i18n"
 	"github.com/willtowle1/parkn/internal/common/logger"
 	"github.com/willtowle1/parkn/internal/config"
 )
@@ -30,6 +31,12 @@ func main() {
 		log.Fatalf("failed to get new logger: %s", err)
 	}
 
+	err = i18n.Load(config.MessageTemplatesPath)
+	if err != nil {
+		logger.Error(ctx, "failed to load message templates", err)
+		os.Exit(1)
+	}
+
 	errs := make(chan error)
 
 	gin.SetMode(gin.ReleaseMode)
@@ -48,13 +55,38 @@ func main() {
 	}
 	database := mongoClient.Database(config.MongoDatabaseName)
 
+	err = app.InitIndexes(ctx, database, *config)
+	if err != nil {
+		logger.Error(ctx, "failed to create indexes", err)
+		os.Exit(1)
+	}
+
 	twilioCreds := twilio.ClientParams{
 		Username: config.TwilioSID,
 		Password: config.TwilioToken,
 	}
 	twilioClient := twilio.NewRestClientWithParams(twilioCreds)
-	app.RegisterParknEndpoints(logger, router, extractorClient, database, twilioCreds)
-	autoAlertService := app.RegisterAutoAlertService(logger, database, twilioClient, config.TwilioNumber)
+
+	archiver, err := app.RegisterArchiver(logger, database, *config)
+	if err != nil {
+		logger.Error(ctx, "failed to get archiver", err)
+		os.Exit(1)
+	}
+
+	consent := app.RegisterConsentStore(logger, database)
+	messenger := app.RegisterMessenger(logger, twilioClient, consent, *config)
+	worker := app.RegisterWorker(logger, *config)
+	alertLog := app.RegisterAlertLog(logger, database, *config)
+	preferences, err := app.RegisterPreferenceStore(logger, database, *config)
+	if err != nil {
+		logger.Error(ctx, "failed to get preference store", err)
+		os.Exit(1)
+	}
+	caller := app.RegisterCaller(logger, twilioClient, consent, *config)
+	retryQueue := app.RegisterRetryQueue(logger, database, *config)
+
+	app.RegisterParknEndpoints(logger, router, extractorClient, database, twilioCreds, archiver, alertLog, preferences, worker, messenger, consent, retryQueue, *config)
+	autoAlertService := app.RegisterAutoAlertService(logger, database, messenger, alertLog, caller, preferences, retryQueue, *config)
 
 	scheduler := gocron.NewScheduler(time.UTC)
 	_, err = scheduler.Every(config.AutoAlertPeriod).Minute().Do(autoAlertService.Alert, ctx)
@@ -62,11 +94,17 @@ func main() {
 		logger.Error(ctx, "failed to start auto service", err)
 	}
 
+	_, err = scheduler.Every(1).Hour().Do(archiver.Purge, ctx)
+	if err != nil {
+		logger.Error(ctx, "failed to start archive purge", err)
+	}
+
 	mainApp := app.NewApp(logger, &http.Server{
 		Addr:    config.ServerAddress,
 		Handler: router,
 	})
 
+	worker.Start(ctx)
 	mainApp.Start(ctx, errs, config.ServerAddress)
 
 	scheduler.StartAsync()
@@ -81,4 +119,8 @@ func main() {
 	} else {
 		logger.Info(ctx, "server terminated successfully")
 	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, time.Duration(config.TerminationGracePeriod)*time.Second)
+	defer cancel()
+	worker.Stop(stopCtx)
 }
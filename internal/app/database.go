@@ -2,13 +2,36 @@ package app
 
 import (
 	"context"
+	"time"
 
 	"github.com/willtowle1/parkn/internal/common/logger"
 	"github.com/willtowle1/parkn/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const (
+	parknCollectionName       = "parkns"
+	ocrCacheCollectionName    = "ocrCache"
+	archiveCollectionName     = "archives"
+	archiveBucketName         = "archiveMedia"
+	pendingCollectionName     = "pendingParkns"
+	inboundCollectionName     = "inboundMessages"
+	sentAlertCollectionName   = "sentAlerts"
+	correctionCollectionName  = "corrections"
+	preferencesCollectionName = "preferences"
+	rateCounterCollectionName = "rateCounters"
+	blockedCollectionName     = "blockedSenders"
+	suppressionCollectionName = "suppressions"
+	consentCollectionName     = "consentEvents"
+	alertRetryCollectionName  = "alertRetries"
+	earlyStatusCollectionName = "earlyMessageStatuses"
+
+	// statuses for messages that never turn out to be alerts, such as replies, are kept this long
+	earlyStatusRetention = 24 * time.Hour
+)
+
 func InitDatabase(ctx context.Context, logger logger.Logger, errs chan error, config config.Config) (*mongo.Client, error) {
 
 	connectOptions := options.Client().
@@ -22,3 +45,185 @@ func InitDatabase(ctx context.Context, logger logger.Logger, errs chan error, co
 
 	return client, nil
 }
+
+// InitIndexes creates the indexes each collection relies on, including TTL indexes for expiring data
+func InitIndexes(ctx context.Context, database *mongo.Database, config config.Config) error {
+
+	ocrCacheTTL := int32((time.Duration(config.OcrCacheTTL) * time.Hour).Seconds())
+
+	_, err := database.Collection(ocrCacheCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "contentHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "hashBands", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(ocrCacheTTL),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(pendingCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "phoneNumber", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	inboundRetention := int32((time.Duration(config.InboundLogRetention) * 24 * time.Hour).Seconds())
+
+	_, err = database.Collection(inboundCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "messageSid", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "phoneNumber", Value: 1}, {Key: "receivedAt", Value: -1}},
+		},
+		{
+			Keys:    bson.D{{Key: "receivedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(inboundRetention),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	sentAlertRetention := int32((time.Duration(config.SentAlertRetention) * 24 * time.Hour).Seconds())
+
+	_, err = database.Collection(sentAlertCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "phoneNumber", Value: 1}, {Key: "sentAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "snoozedUntil", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "retryAt", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "callSid", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "messageSid", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "sentAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(sentAlertRetention),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(earlyStatusCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "messageSid", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "reportedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(earlyStatusRetention.Seconds())),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// dead-lettered retries stay until an admin re-drives them, so there is no TTL
+	_, err = database.Collection(alertRetryCollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(preferencesCollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "phoneNumber", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(rateCounterCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(blockedCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "phoneNumber", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(suppressionCollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "phoneNumber", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// consent events are kept for audits, so unlike most logs they never expire
+	_, err = database.Collection(consentCollectionName).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "phoneNumber", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(correctionCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "phrase", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = database.Collection(archiveCollectionName).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "parknId", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "createdAt", Value: 1}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
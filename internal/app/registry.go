@@ -1,10 +1,15 @@
 package app
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	visionApi "cloud.google.com/go/vision/apiv1"
 	"github.com/gin-gonic/gin"
 	"github.com/twilio/twilio-go"
 	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/config"
 	"github.com/willtowle1/parkn/internal/controller"
 	"github.com/willtowle1/parkn/internal/dal"
 	"github.com/willtowle1/parkn/internal/model"
@@ -12,31 +17,219 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func RegisterParknEndpoints(logger logger.Logger, router gin.IRouter, extractorClient *visionApi.ImageAnnotatorClient, database *mongo.Database, twilioCreds twilio.ClientParams) {
+const (
+	archiveStoreGridFs     = "gridfs"
+	archiveStoreFilesystem = "filesystem"
+
+	errUnknownArchiveStore = "unknown archive store"
+	errInvalidLeadTimes    = "invalid default alert lead times"
+	errInvalidQuietHours   = "invalid default quiet hours"
+
+	// where Twilio reports how alert calls ended and whether texts were delivered, relative to the public webhook base url
+	voiceStatusPath   = "/api/v1/parkn/voice/status"
+	messageStatusPath = "/api/v1/parkn/sms/status"
+)
+
+func RegisterArchiver(logger logger.Logger, database *mongo.Database, config config.Config) (*service.Archiver, error) {
+
+	var blobStore service.IBlobStore
+	var err error
+	switch config.ArchiveStore {
+	case archiveStoreGridFs:
+		blobStore, err = dal.NewGridFsBlobStore(logger, database, archiveBucketName)
+	case archiveStoreFilesystem:
+		blobStore, err = dal.NewFileBlobStore(logger, config.ArchivePath)
+	default:
+		err = fmt.Errorf("%s: %s", errUnknownArchiveStore, config.ArchiveStore)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archiveCollection := database.Collection(archiveCollectionName)
+	archiveRepository := dal.NewRepository[model.SubmissionArchive](logger, *archiveCollection)
 
-	parknCollection := database.Collection("parkns")
+	retention := time.Duration(config.ArchiveRetention) * 24 * time.Hour
+
+	return service.NewArchiver(logger, blobStore, archiveRepository, retention), nil
+}
+
+func RegisterParknEndpoints(logger logger.Logger, router gin.IRouter, extractorClient *visionApi.ImageAnnotatorClient, database *mongo.Database, twilioCreds twilio.ClientParams, archiver *service.Archiver, alertLog *service.AlertLog, preferences *service.PreferenceStore, worker *service.Worker, messenger *service.Messenger, consent *service.ConsentStore, retryQueue *service.RetryQueue, config config.Config) {
+
+	parknCollection := database.Collection(parknCollectionName)
 	parknRepository := dal.NewRepository[model.Parkn](logger, *parknCollection)
 
+	pendingCollection := database.Collection(pendingCollectionName)
+	pendingRepository := dal.NewRepository[model.PendingParkn](logger, *pendingCollection)
+
+	correctionCollection := database.Collection(correctionCollectionName)
+	correctionRepository := dal.NewRepository[model.Correction](logger, *correctionCollection)
+
+	ocrCacheCollection := database.Collection(ocrCacheCollectionName)
+	ocrCacheRepository := dal.NewRepository[model.OcrCacheEntry](logger, *ocrCacheCollection)
+
 	parknTextExtractor := service.NewTextExtractor(logger, extractorClient)
+	cachedTextExtractor := service.NewCachedTextExtractor(logger, parknTextExtractor, ocrCacheRepository, config.OcrCachePerceptualHash, config.OcrCacheMaxHashDist)
 	parknDateSniper := service.NewDateSniper(logger)
+	imageQualityChecker := service.NewImageQualityChecker(logger, service.ImageQualityThresholds{
+		MinWidth:      config.ImageMinWidth,
+		MinHeight:     config.ImageMinHeight,
+		MinBrightness: config.ImageMinBrightness,
+		MinSharpness:  config.ImageMinSharpness,
+	})
 
 	httpClient := service.NewHttpClient(logger, parknTextExtractor, twilioCreds)
 
-	parknService := service.NewParknService(logger, parknTextExtractor, parknDateSniper, parknRepository, httpClient)
+	// without a reverse geocoding endpoint only streets named in the shared location are used
+	var geocoder service.IGeocoder
+	if len(config.GeocoderUrl) > 0 {
+		geocoder = service.NewGeocoder(logger, config.GeocoderUrl)
+	}
+	locator := service.NewLocator(logger, geocoder)
 
-	parknController := controller.NewController(logger, parknService)
+	pendingTTL := time.Duration(config.PendingConfirmationTTL) * time.Minute
+	parknService := service.NewParknService(logger, cachedTextExtractor, parknDateSniper, parknRepository, httpClient, archiver, imageQualityChecker, pendingRepository, pendingTTL, correctionRepository, locator)
+
+	inboundCollection := database.Collection(inboundCollectionName)
+	inboundRepository := dal.NewRepository[model.InboundMessage](logger, *inboundCollection)
+	inboundLog := service.NewInboundLog(logger, inboundRepository)
+
+	correctionReport := service.NewCorrectionReport(logger, correctionRepository)
+
+	rateLimiter := registerRateLimiter(logger, database, config)
+
+	parknController := controller.NewController(logger, parknService, alertLog, preferences, worker, messenger, inboundLog, rateLimiter, consent)
+	adminController := controller.NewAdminController(logger, config.AdminApiKey, cachedTextExtractor, archiver, correctionReport, rateLimiter, consent, retryQueue)
+
+	webhookAuth := controller.TwilioSignature(logger, twilioCreds.Password, config.WebhookBaseUrl, config.WebhookSignatureDisabled)
 
 	apiRouter := router.Group("/api")
-	parknController.RegisterRoutes(apiRouter)
+	parknController.RegisterRoutes(apiRouter, webhookAuth)
+	adminController.RegisterRoutes(apiRouter)
+}
+
+func registerRateLimiter(logger logger.Logger, database *mongo.Database, config config.Config) *service.RateLimiter {
+
+	rateCounterCollection := database.Collection(rateCounterCollectionName)
+	rateCounterRepository := dal.NewRepository[model.RateCounter](logger, *rateCounterCollection)
+
+	blockedCollection := database.Collection(blockedCollectionName)
+	blockedRepository := dal.NewRepository[model.BlockedSender](logger, *blockedCollection)
+
+	policy := service.RateLimitPolicy{
+		Window:         time.Duration(config.RateLimitWindow) * time.Minute,
+		SenderMessages: config.RateLimitSenderMessages,
+		SenderMedia:    config.RateLimitSenderMedia,
+		GlobalMedia:    config.RateLimitGlobalMedia,
+		StrikesToBlock: config.RateLimitStrikesToBlock,
+		StrikeWindow:   time.Duration(config.RateLimitStrikeWindow) * time.Hour,
+		BlockDuration:  time.Duration(config.RateLimitBlockDuration) * time.Hour,
+	}
+
+	return service.NewRateLimiter(logger, rateCounterRepository, blockedRepository, policy)
+}
+
+// RegisterRetryQueue keeps alerts that failed to send, for the auto alert service to retry and admins to re-drive
+func RegisterRetryQueue(logger logger.Logger, database *mongo.Database, config config.Config) *service.RetryQueue {
+
+	alertRetryCollection := database.Collection(alertRetryCollectionName)
+	alertRetryRepository := dal.NewRepository[model.AlertRetry](logger, *alertRetryCollection)
+
+	policy := service.RetryPolicy{
+		MaxAttempts: config.AlertRetryMaxAttempts,
+		BaseDelay:   time.Duration(config.AlertRetryBaseDelay) * time.Second,
+		MaxDelay:    time.Duration(config.AlertRetryMaxDelay) * time.Minute,
+	}
+
+	return service.NewRetryQueue(logger, alertRetryRepository, policy)
+}
+
+func RegisterConsentStore(logger logger.Logger, database *mongo.Database) *service.ConsentStore {
+
+	suppressionCollection := database.Collection(suppressionCollectionName)
+	suppressionRepository := dal.NewRepository[model.Suppression](logger, *suppressionCollection)
+
+	consentCollection := database.Collection(consentCollectionName)
+	consentRepository := dal.NewRepository[model.ConsentEvent](logger, *consentCollection)
+
+	return service.NewConsentStore(logger, suppressionRepository, consentRepository)
+}
+
+// RegisterMessenger sends texts. Undelivered alert texts are only retried when the public webhook base url is set.
+func RegisterMessenger(logger logger.Logger, twilioClient *twilio.RestClient, consent *service.ConsentStore, config config.Config) *service.Messenger {
+	statusCallbackUrl := statusCallback(config, messageStatusPath)
+	return service.NewMessenger(logger, twilioClient, config.TwilioNumber, config.WhatsAppNumber, statusCallbackUrl, consent)
+}
+
+func RegisterAlertLog(logger logger.Logger, database *mongo.Database, config config.Config) *service.AlertLog {
+
+	sentAlertCollection := database.Collection(sentAlertCollectionName)
+	sentAlertRepository := dal.NewRepository[model.SentAlert](logger, *sentAlertCollection)
+	earlyStatusCollection := database.Collection(earlyStatusCollectionName)
+	earlyStatusRepository := dal.NewRepository[model.DeliveryEvent](logger, *earlyStatusCollection)
+
+	defaultSnooze := time.Duration(config.SnoozeDefault) * time.Minute
+	maxSnooze := time.Duration(config.SnoozeMax) * time.Hour
+	callRetryDelay := time.Duration(config.VoiceRetryDelay) * time.Minute
+	textRetryDelay := time.Duration(config.TextRetryDelay) * time.Minute
+
+	return service.NewAlertLog(logger, sentAlertRepository, earlyStatusRepository, defaultSnooze, maxSnooze, callRetryDelay, textRetryDelay)
+}
+
+// RegisterPreferenceStore keeps user settings. Users who never sent REMIND or QUIET get the lead times and quiet
+// hours from config, where empty quiet hours turn them off.
+func RegisterPreferenceStore(logger logger.Logger, database *mongo.Database, config config.Config) (*service.PreferenceStore, error) {
+
+	defaultLeadTimes, valid := model.ParseLeadTimes(config.AlertLeadTimes)
+	if !valid {
+		return nil, fmt.Errorf("%s: %s", errInvalidLeadTimes, config.AlertLeadTimes)
+	}
+
+	var defaultQuiet model.QuietHours
+	if len(config.QuietHours) > 0 {
+		defaultQuiet, valid = model.ParseQuietHours(config.QuietHours)
+		if !valid {
+			return nil, fmt.Errorf("%s: %s", errInvalidQuietHours, config.QuietHours)
+		}
+	}
+
+	preferencesCollection := database.Collection(preferencesCollectionName)
+	preferencesRepository := dal.NewRepository[model.Preferences](logger, *preferencesCollection)
+
+	return service.NewPreferenceStore(logger, preferencesRepository, defaultLeadTimes, defaultQuiet), nil
+}
+
+// RegisterCaller places alert calls. Unanswered calls are only retried when the public webhook base url is set.
+func RegisterCaller(logger logger.Logger, twilioClient *twilio.RestClient, consent *service.ConsentStore, config config.Config) *service.Caller {
+
+	statusCallbackUrl := statusCallback(config, voiceStatusPath)
+	return service.NewCaller(logger, twilioClient, config.TwilioNumber, statusCallbackUrl, consent)
+}
+
+// statusCallback is the public url Twilio reports statuses to, or empty when there is no public webhook base url
+func statusCallback(config config.Config, path string) string {
+	if len(config.WebhookBaseUrl) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(config.WebhookBaseUrl, "/") + path
+}
+
+func RegisterWorker(logger logger.Logger, config config.Config) *service.Worker {
+	timeout := time.Duration(config.WorkerJobTimeout) * time.Second
+	return service.NewWorker(logger, config.WorkerCount, config.WorkerQueueSize, timeout)
 }
 
-func RegisterAutoAlertService(logger logger.Logger, database *mongo.Database, twilioClient *twilio.RestClient, twilioNumber string) *service.AutoAlertService {
+func RegisterAutoAlertService(logger logger.Logger, database *mongo.Database, messenger *service.Messenger, alertLog *service.AlertLog, caller *service.Caller, preferences *service.PreferenceStore, retryQueue *service.RetryQueue, config config.Config) *service.AutoAlertService {
 
-	parknCollection := database.Collection("parkns")
+	parknCollection := database.Collection(parknCollectionName)
 	parknRepository := dal.NewRepository[model.Parkn](logger, *parknCollection)
-	alertService := service.NewAlertService(logger, parknRepository)
+	alertService := service.NewAlertService(logger, parknRepository, service.NewDateSniper(logger))
 
-	autoAlertService := service.NewAutoAlertService(logger, alertService, twilioClient, twilioNumber)
+	templates := service.AlertTemplates{
+		Alert:    config.WhatsAppAlertTemplate,
+		Reminder: config.WhatsAppReminderTemplate,
+	}
+	autoAlertService := service.NewAutoAlertService(logger, alertService, messenger, alertLog, templates, caller, preferences, retryQueue, config.VoiceMaxAttempts, config.TextMaxAttempts)
 
 	return autoAlertService
 }
@@ -1,6 +1,12 @@
 package errs
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDuplicateKey is returned by the data layer when a write violates a unique index
+var ErrDuplicateKey = errors.New("duplicate key")
 
 func WrapError(message string, err error) error { return fmt.Errorf("%s: %w", message, err) }
 
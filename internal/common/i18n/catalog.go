@@ -0,0 +1,261 @@
+package i18n
+
+import (
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/model"
+)
+
+// Key names a message in the catalog. Every locale defines every key, and every message is a text/template
+// template run against Data.
+type Key string
+
+const (
+	Help             Key = "help"
+	NoAlerts         Key = "noAlerts"
+	ListHeader       Key = "listHeader"
+	ListItem         Key = "listItem"
+	NextSweep        Key = "nextSweep"
+	CanceledOne      Key = "canceledOne"
+	InvalidCancel    Key = "invalidCancel"
+	Confirmed        Key = "confirmed"
+	NothingToConfirm Key = "nothingToConfirm"
+	Moved            Key = "moved"
+	Snoozed          Key = "snoozed"
+	NoAlertSent      Key = "noAlertSent"
+	InvalidSnooze    Key = "invalidSnooze"
+	Corrected        Key = "corrected"
+	InvalidWrong     Key = "invalidWrong"
+	NothingToFix     Key = "nothingToFix"
+	VoiceOn          Key = "voiceOn"
+	VoiceOff         Key = "voiceOff"
+	InvalidCall      Key = "invalidCall"
+	Named            Key = "named"
+	InvalidName      Key = "invalidName"
+	NothingToName    Key = "nothingToName"
+	RemindersSet     Key = "remindersSet"
+	RemindersCurrent Key = "remindersCurrent"
+	InvalidRemind    Key = "invalidRemind"
+	QuietSet         Key = "quietSet"
+	QuietCurrent     Key = "quietCurrent"
+	QuietOff         Key = "quietOff"
+	InvalidQuiet     Key = "invalidQuiet"
+	OptedOut         Key = "optedOut"
+	OptedIn          Key = "optedIn"
+	LanguageSet      Key = "languageSet"
+	LanguageOptions  Key = "languageOptions"
+	WorkerBusy       Key = "workerBusy"
+	Throttled        Key = "throttled"
+	ReadingSign      Key = "readingSign"
+	LocationStreet   Key = "locationStreet"
+	LocationNoStreet Key = "locationNoStreet"
+	LocationMoved    Key = "locationMoved"
+	NoLocation       Key = "noLocation"
+	NothingToLocate  Key = "nothingToLocate"
+	Error            Key = "error"
+	MissingMedia     Key = "missingMedia"
+	LocationFailed   Key = "locationFailed"
+	ConfirmReading   Key = "confirmReading"
+	PhotoCombined    Key = "photoCombined"
+	PhotoOne         Key = "photoOne"
+	CancelHint       Key = "cancelHint"
+
+	Alert        Key = "alert"
+	AlertSubject Key = "alertSubject"
+	Reminder     Key = "reminder"
+	Voice        Key = "voice"
+	WhenMinutes  Key = "whenMinutes"
+	WhenToday    Key = "whenToday"
+	WhenTomorrow Key = "whenTomorrow"
+	WhenLater    Key = "whenLater"
+
+	AdviceNoFrequency   Key = "adviceNoFrequency"
+	AdviceNoText        Key = "adviceNoText"
+	AdviceImageTooSmall Key = "adviceImageTooSmall"
+	AdviceImageTooDark  Key = "adviceImageTooDark"
+	AdviceImageBlurry   Key = "adviceImageBlurry"
+)
+
+// Default is the language used until a user picks one or their first message is recognized
+const Default = "en"
+
+// Locale holds one language's messages, keyword aliases and date, time and schedule formatting
+type Locale struct {
+	// Tag is the code users send with LANG, such as "es"
+	Tag string
+	// Name is the language's name in that language
+	Name string
+	// VoiceLanguage is the language Twilio <Say> reads alert calls in
+	VoiceLanguage string
+
+	messages map[Key]string
+	// templates are the parsed messages, along with any overrides from config
+	templates map[Key]*template.Template
+	// aliases maps keywords in this language to the English keyword they stand for
+	aliases map[string]string
+	// names are extra words accepted after LANG, such as "SPANISH"
+	names []string
+	// hints are common words used to recognize the language of a first message
+	hints []string
+
+	dateLayout      string
+	shortDateLayout string
+	clockLayout     string
+	dayLayout       string
+	dayTimeLayout   string
+	nameReplacer    *strings.Replacer
+	schedule        func(schedule model.Schedule) string
+	window          func(schedule model.Schedule) string
+}
+
+var locales = map[string]*Locale{
+	english.Tag: english,
+	spanish.Tag: spanish,
+}
+
+// Get returns the locale for a tag, falling back to the default for unknown or empty tags
+func Get(tag string) *Locale {
+	if locale, exists := locales[strings.ToLower(tag)]; exists {
+		return locale
+	}
+	return locales[Default]
+}
+
+// Locales returns every supported locale ordered by tag
+func Locales() []*Locale {
+	all := make([]*Locale, 0, len(locales))
+	for _, locale := range locales {
+		all = append(all, locale)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Tag < all[j].Tag
+	})
+	return all
+}
+
+// Find returns the locale a LANG argument names, by tag or by name
+func Find(name string) (*Locale, bool) {
+	name = strings.ToUpper(name)
+	for _, locale := range locales {
+		if name == strings.ToUpper(locale.Tag) || name == strings.ToUpper(locale.Name) {
+			return locale, true
+		}
+		for _, alias := range locale.names {
+			if name == alias {
+				return locale, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Keyword returns the English keyword a word stands for in any language, or the word itself
+func Keyword(word string) string {
+	for _, locale := range locales {
+		if keyword, exists := locale.aliases[word]; exists {
+			return keyword
+		}
+	}
+	return word
+}
+
+// Detect guesses the language of a message from common words and returns its tag. It reports false
+// when no language stands out, such as for a bare photo caption or coordinates.
+func Detect(text string) (string, bool) {
+
+	words := strings.FieldsFunc(strings.ToUpper(text), func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r > 127)
+	})
+
+	best, bestScore, tied := Default, 0, false
+	for _, locale := range locales {
+		score := 0
+		for _, word := range words {
+			for _, hint := range locale.hints {
+				if word == hint {
+					score++
+					break
+				}
+			}
+		}
+		if score > bestScore {
+			best, bestScore, tied = locale.Tag, score, false
+		} else if score == bestScore && score > 0 {
+			tied = true
+		}
+	}
+
+	if tied || bestScore == 0 {
+		return Default, false
+	}
+	return best, true
+}
+
+// Date is the full date used in lists and confirmations
+func (l *Locale) Date(t time.Time) string {
+	return l.format(t, l.dateLayout)
+}
+
+// ShortDate is the day and month used when proposing a reading
+func (l *Locale) ShortDate(t time.Time) string {
+	return l.format(t, l.shortDateLayout)
+}
+
+// Clock is the time of day
+func (l *Locale) Clock(t time.Time) string {
+	return l.format(t, l.clockLayout)
+}
+
+// SpokenDate is the date read out on alert calls, with the time when withTime is set
+func (l *Locale) SpokenDate(t time.Time, withTime bool) string {
+	if withTime {
+		return l.format(t, l.dayTimeLayout)
+	}
+	return l.format(t, l.dayLayout)
+}
+
+// Schedule describes a sweeping schedule such as "1st & 3rd Monday 8–10am"
+func (l *Locale) Schedule(schedule model.Schedule) string {
+	return l.schedule(schedule)
+}
+
+// Window describes the sweeping window such as "8–10am", or is empty when the sign gave none
+func (l *Locale) Window(schedule model.Schedule) string {
+	if !schedule.HasWindow {
+		return ""
+	}
+	return l.window(schedule)
+}
+
+// format lays out t in EST and swaps Go's English month and weekday names for the locale's own
+func (l *Locale) format(t time.Time, layout string) string {
+	loc, _ := time.LoadLocation("EST")
+	formatted := t.In(loc).Format(layout)
+	if l.nameReplacer == nil {
+		return formatted
+	}
+	return l.nameReplacer.Replace(formatted)
+}
+
+// newNameReplacer swaps full English month and weekday names before the abbreviated ones they start with
+func newNameReplacer(months, shortMonths [12]string, weekdays, shortWeekdays [7]string) *strings.Replacer {
+
+	pairs := make([]string, 0, 2*(12+12+7+7))
+	for i := 0; i < 12; i++ {
+		pairs = append(pairs, time.Month(i+1).String(), months[i])
+	}
+	for i := 0; i < 7; i++ {
+		pairs = append(pairs, time.Weekday(i).String(), weekdays[i])
+	}
+	for i := 0; i < 12; i++ {
+		pairs = append(pairs, time.Month(i + 1).String()[:3], shortMonths[i])
+	}
+	for i := 0; i < 7; i++ {
+		pairs = append(pairs, time.Weekday(i).String()[:3], shortWeekdays[i])
+	}
+
+	return strings.NewReplacer(pairs...)
+}
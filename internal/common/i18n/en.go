@@ -0,0 +1,93 @@
+package i18n
+
+import "github.com/willtowle1/parkn/internal/model"
+
+var english = &Locale{
+	Tag:           "en",
+	Name:          "English",
+	VoiceLanguage: "en-US",
+
+	messages: map[Key]string{
+		Help:             "Parkn: text a photo of a street sweeping sign, or type the schedule like \"2nd & 4th Tuesday 9-11am\", then reply YES to confirm and we'll alert you before the sweep. Commands: LIST - your upcoming alerts. STATUS - your next sweep. CANCEL <n> - cancel alert n from LIST. MOVED - stop reminders for the alert we just sent. SNOOZE <2h|30m> - remind me again later. WRONG <schedule> - fix the schedule of your latest alert. CALL ME / TEXT ME - get alerts as a phone call or a text. NAME <nickname> - name the car for your latest alert. REMIND <8pm,1h> - when to remind you before each sweep. QUIET <10pm-7am|OFF> - hours we hold alerts. LANG <en|es> - change language. STOP or CANCEL - cancel all alerts and stop all messages, START to resume. HELP - this message.",
+		NoAlerts:         "You have no upcoming alerts. Text a photo of a street sweeping sign to create one.",
+		ListHeader:       "Your upcoming alerts:",
+		ListItem:         "{{.Index}}. {{date .MoveBy}}{{with .Nickname}} - {{.}}{{end}}{{with .Street}} ({{.}}){{end}}",
+		NextSweep:        "Your next street sweeping{{with .Nickname}} for {{.}}{{end}} is on {{date .MoveBy}}.",
+		CanceledOne:      "Canceled alert {{.Index}}.",
+		InvalidCancel:    "Reply CANCEL <n> with a number from LIST to cancel one alert, or STOP to cancel them all and stop all messages.",
+		Confirmed:        "Success - parkn alert created successfully. You will be alerted to move your car by {{date .MoveBy}}, and again before every sweep after that until you cancel or share a new location. {{.CancelHint}}",
+		NothingToConfirm: "There's nothing waiting to be saved. Text a photo of a street sweeping sign or type the schedule to create an alert.",
+		Moved:            "Thanks for moving your car! We won't remind you again about this sweep, but we'll alert you before the next one.",
+		Snoozed:          "OK, we'll remind you again at {{clock .At}}.",
+		NoAlertSent:      "We haven't sent you an alert yet. Text LIST to see your upcoming alerts.",
+		InvalidSnooze:    "Reply SNOOZE to be reminded again later, or SNOOZE with a time like SNOOZE 2h or SNOOZE 30m.",
+		Corrected:        "Thanks, fixed - your alert now follows {{schedule .Schedule}}, next on {{shortDate .MoveBy}}.",
+		InvalidWrong:     "Reply WRONG followed by the correct schedule, like \"WRONG 2nd & 4th Tuesday 9-11am\".",
+		NothingToFix:     "You have no saved alerts to correct. Text a photo of a street sweeping sign or type the schedule to create one.",
+		VoiceOn:          "OK - we'll call you with your alerts from now on. Text TEXT ME to switch back to texts.",
+		VoiceOff:         "OK - we'll text you your alerts from now on. Text CALL ME to get a phone call instead.",
+		InvalidCall:      "Reply CALL ME to get alerts as a phone call or TEXT ME to get them by text.",
+		Named:            "OK - your latest alert is now for {{.Nickname}}.",
+		InvalidName:      "Reply NAME followed by a name for your car, like \"NAME the van\".",
+		NothingToName:    "You have no saved alerts to name. Text a photo of a street sweeping sign or type the schedule to create one.",
+		RemindersSet:     "OK - your reminders before each sweep are now {{.Reminders}}. A time of day like 8pm means the night before.",
+		RemindersCurrent: "Your reminders before each sweep are {{.Reminders}}. Reply REMIND with up to 4 times to change them, like \"REMIND 8pm, 1h\" for 8pm the night before and 1 hour before.",
+		InvalidRemind:    "Reply REMIND with up to 4 times before each sweep, like \"REMIND 12h, 1h\" for 12 hours and 1 hour before or \"REMIND 8pm\" for 8pm the night before. REMIND DEFAULT goes back to the usual reminders.",
+		QuietSet:         "OK - we'll hold alerts between {{.Quiet}} and send them after, or just before if the sweep starts first. Reply QUIET OFF to get them any time.",
+		QuietCurrent:     "We hold alerts between {{.Quiet}}. Reply QUIET with other hours, like \"QUIET 11pm-6am\", or QUIET OFF to get alerts any time.",
+		QuietOff:         "Quiet hours are off - alerts can come at any time. Reply QUIET with hours, like \"QUIET 10pm-7am\", to hold them overnight.",
+		InvalidQuiet:     "Reply QUIET with the hours to hold alerts, like \"QUIET 10pm-7am\", QUIET OFF to get them any time, or QUIET DEFAULT for the usual hours.",
+		OptedOut:         "You've been unsubscribed from Parkn and your alerts were canceled. You won't get any more messages. Reply START to subscribe again.",
+		OptedIn:          "You're subscribed to Parkn again. Text a photo of a street sweeping sign to create an alert. Reply STOP to unsubscribe.",
+		LanguageSet:      "OK - we'll write to you in English from now on.",
+		LanguageOptions:  "Reply LANG followed by a language: {{.Languages}}.",
+		WorkerBusy:       "We're busy reading other signs right now. Please send your photo again in a few minutes.",
+		Throttled:        "You're sending messages faster than we can keep up with. Please wait a while before sending more.",
+		ReadingSign:      "Got it, reading your sign…",
+		LocationStreet:   "Got it - we'll remind you to move your car on {{.Street}}.",
+		LocationNoStreet: "Got it - we saved where your car is parked.",
+		LocationMoved:    "Got it - your car has moved, so we stopped the repeating alerts{{with .Street}} for {{.}}{{end}}. Text a photo of the sweeping sign where it's parked now to get alerts there.",
+		NoLocation:       "I couldn't find a location in that message. Share your location from Maps, or type the schedule like \"2nd & 4th Tuesday 9-11am\".",
+		NothingToLocate:  "Send a photo of the street sweeping sign first, then share your location.",
+		Error:            "Sorry, something went wrong on our end. Please try again in a few minutes.",
+		MissingMedia:     "Send a photo of the street sweeping sign, type its schedule like \"2nd & 4th Tuesday 9-11am\", or share your location.",
+		LocationFailed:   "Sorry, we couldn't save your location. Please share it again in a few minutes.",
+		ConfirmReading:   "I read this as {{schedule .Schedule}}, next on {{shortDate .MoveBy}}{{with .Street}} for your car on {{.}}{{end}}{{.Note}}. Reply YES to save or send the correct schedule.",
+		PhotoCombined:    " (read from all {{.Total}} photos combined)",
+		PhotoOne:         " (read from photo {{.Index}} of {{.Total}})",
+		CancelHint:       "Text LIST to see your alerts and CANCEL <n> to cancel one.",
+
+		Alert:        "Move your car{{with .Nickname}} ({{.}}){{end}}{{with .Street}} on {{.}}{{end}} - street sweeping starts {{when .}}{{with window .Schedule}} ({{.}}){{end}}! Reply MOVED once you have, or SNOOZE 2h to be reminded again later.",
+		AlertSubject: "your car{{with .Nickname}} ({{.}}){{end}}{{with .Street}} on {{.}}{{end}}",
+		Reminder:     "Reminder: move your car{{with .Nickname}} ({{.}}){{end}}{{with .Street}} on {{.}}{{end}} for street sweeping! Reply MOVED once you have, or SNOOZE to be reminded again.",
+		Voice:        "Hello, this is Parkn. Street sweeping{{with .Street}} on {{.}}{{end}} starts {{spokenDate .MoveBy .HasTime}}. Please move your car{{with .Nickname}}, {{.}},{{end}} before then. Text MOVED once you have, or TEXT ME to get alerts by text instead.",
+		WhenMinutes:  "in {{.Minutes}} minutes",
+		WhenToday:    "today{{if .HasTime}} at {{clock .MoveBy}}{{end}}",
+		WhenTomorrow: "tomorrow{{if .HasTime}} at {{clock .MoveBy}}{{end}}",
+		WhenLater:    "on {{spokenDate .MoveBy .HasTime}}",
+
+		AdviceNoFrequency:   "I couldn't find a sweeping schedule — make sure the whole schedule panel is in frame, or type it like \"2nd & 4th Tuesday 9-11am\"",
+		AdviceNoText:        "I couldn't read any text in that photo — point the camera straight at the sign and fill the frame with it",
+		AdviceImageTooSmall: "Photo is too small to read — send the original photo instead of a thumbnail or screenshot",
+		AdviceImageTooDark:  "Photo looks too dark — turn on your flash or move closer to a light",
+		AdviceImageBlurry:   "Photo looks blurry — get closer and hold steady",
+	},
+
+	aliases: map[string]string{
+		"LANGUAGE": "LANG",
+	},
+	names: []string{"ENGLISH", "INGLES", "INGLÉS"},
+	hints: []string{
+		"THE", "AND", "IS", "MY", "HI", "HELLO", "THANKS", "PLEASE", "WHAT", "STREET", "SWEEPING", "CLEANING",
+		"CAR", "EVERY", "FIRST", "SECOND", "THIRD", "FOURTH", "MONDAY", "TUESDAY", "WEDNESDAY", "THURSDAY",
+		"FRIDAY", "SATURDAY", "SUNDAY", "HELP", "YES", "LIST", "CANCEL",
+	},
+
+	dateLayout:      "01-02-2006",
+	shortDateLayout: "Jan 2",
+	clockLayout:     "3:04pm",
+	dayLayout:       "Monday, January 2",
+	dayTimeLayout:   "Monday, January 2 at 3:04 PM",
+	schedule:        func(schedule model.Schedule) string { return schedule.String() },
+	window:          func(schedule model.Schedule) string { return schedule.Window() },
+}
@@ -0,0 +1,150 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/willtowle1/parkn/internal/model"
+)
+
+var (
+	spanishOrdinals = map[int]string{1: "1.er", 2: "2.º", 3: "3.er", 4: "4.º", 5: "5.º"}
+	// indexed by model.Schedule.DayOfWeek, which starts at 1 for Monday
+	spanishScheduleDays = map[int]string{1: "lunes", 2: "martes", 3: "miércoles", 4: "jueves", 5: "viernes", 6: "sábado", 7: "domingo"}
+)
+
+var spanish = &Locale{
+	Tag:           "es",
+	Name:          "Español",
+	VoiceLanguage: "es-MX",
+
+	messages: map[Key]string{
+		Help:             "Parkn: envía una foto de un letrero de limpieza de calles, o escribe el horario como \"2nd & 4th Tuesday 9-11am\", y responde SI para confirmar; te avisaremos antes de la limpieza. Comandos: LISTA - tus próximos avisos. ESTADO - tu próxima limpieza. CANCELAR <n> - cancela el aviso n de LISTA. MOVIDO - detiene los recordatorios del aviso que acabamos de enviar. POSPONER <2h|30m> - recuérdame más tarde. CORREGIR <horario> - corrige el horario de tu último aviso. LLAMAME / TEXTEAME - recibe los avisos por llamada o por mensaje. NOMBRE <apodo> - ponle nombre al carro de tu último aviso. RECORDAR <20:00,1h> - cuándo recordarte antes de cada limpieza. SILENCIO <22:00-07:00|NO> - horas en que no enviamos avisos. IDIOMA <en|es> - cambia el idioma. STOP o CANCELAR - cancela todos los avisos y deja de recibir mensajes, START para volver. AYUDA - este mensaje.",
+		NoAlerts:         "No tienes avisos próximos. Envía una foto de un letrero de limpieza de calles para crear uno.",
+		ListHeader:       "Tus próximos avisos:",
+		ListItem:         "{{.Index}}. {{date .MoveBy}}{{with .Nickname}} - {{.}}{{end}}{{with .Street}} ({{.}}){{end}}",
+		NextSweep:        "Tu próxima limpieza de calles{{with .Nickname}} para {{.}}{{end}} es el {{date .MoveBy}}.",
+		CanceledOne:      "Se canceló el aviso {{.Index}}.",
+		InvalidCancel:    "Responde CANCELAR <n> con un número de LISTA para cancelar un aviso, o STOP para cancelarlos todos y dejar de recibir mensajes.",
+		Confirmed:        "Listo - aviso de parkn creado. Te avisaremos que muevas tu carro antes del {{date .MoveBy}}, y de nuevo antes de cada limpieza siguiente hasta que lo canceles o compartas una nueva ubicación. {{.CancelHint}}",
+		NothingToConfirm: "No hay nada pendiente por guardar. Envía una foto de un letrero de limpieza de calles o escribe el horario para crear un aviso.",
+		Moved:            "¡Gracias por mover tu carro! No te recordaremos de nuevo esta limpieza, pero te avisaremos antes de la siguiente.",
+		Snoozed:          "De acuerdo, te lo recordaremos de nuevo a las {{clock .At}}.",
+		NoAlertSent:      "Todavía no te hemos enviado ningún aviso. Envía LISTA para ver tus próximos avisos.",
+		InvalidSnooze:    "Responde POSPONER para que te lo recordemos más tarde, o POSPONER con un tiempo como POSPONER 2h o POSPONER 30m.",
+		Corrected:        "Gracias, corregido - tu aviso ahora sigue el horario {{schedule .Schedule}}, próximo el {{shortDate .MoveBy}}.",
+		InvalidWrong:     "Responde CORREGIR seguido del horario correcto, como \"CORREGIR 2nd & 4th Tuesday 9-11am\".",
+		NothingToFix:     "No tienes avisos guardados que corregir. Envía una foto de un letrero de limpieza de calles o escribe el horario para crear uno.",
+		VoiceOn:          "De acuerdo - desde ahora te llamaremos con tus avisos. Envía TEXTEAME para volver a recibirlos por mensaje.",
+		VoiceOff:         "De acuerdo - desde ahora te enviaremos tus avisos por mensaje. Envía LLAMAME para recibir una llamada.",
+		InvalidCall:      "Responde LLAMAME para recibir los avisos por llamada o TEXTEAME para recibirlos por mensaje.",
+		Named:            "De acuerdo - tu último aviso ahora es para {{.Nickname}}.",
+		InvalidName:      "Responde NOMBRE seguido de un nombre para tu carro, como \"NOMBRE la camioneta\".",
+		NothingToName:    "No tienes avisos guardados a los que ponerles nombre. Envía una foto de un letrero de limpieza de calles o escribe el horario para crear uno.",
+		RemindersSet:     "De acuerdo - ahora tus recordatorios antes de cada limpieza son {{.Reminders}}. Una hora del día como 8pm o 20:00 significa la noche anterior.",
+		RemindersCurrent: "Tus recordatorios antes de cada limpieza son {{.Reminders}}. Responde RECORDAR con hasta 4 horas para cambiarlos, como \"RECORDAR 20:00, 1h\" para las 8 de la noche anterior y 1 hora antes.",
+		InvalidRemind:    "Responde RECORDAR con hasta 4 horas antes de cada limpieza, como \"RECORDAR 12h, 1h\" para 12 horas y 1 hora antes o \"RECORDAR 20:00\" para las 8 de la noche anterior. RECORDAR PREDETERMINADO vuelve a los recordatorios habituales.",
+		QuietSet:         "De acuerdo - no enviaremos avisos de {{.Quiet}}; te llegarán después, o justo antes si la limpieza empieza primero. Responde SILENCIO NO para recibirlos a cualquier hora.",
+		QuietCurrent:     "No enviamos avisos de {{.Quiet}}. Responde SILENCIO con otras horas, como \"SILENCIO 23:00-06:00\", o SILENCIO NO para recibir avisos a cualquier hora.",
+		QuietOff:         "Las horas de silencio están desactivadas - los avisos pueden llegar a cualquier hora. Responde SILENCIO con las horas, como \"SILENCIO 22:00-07:00\", para retenerlos de noche.",
+		InvalidQuiet:     "Responde SILENCIO con las horas en que no quieres avisos, como \"SILENCIO 22:00-07:00\", SILENCIO NO para recibirlos a cualquier hora, o SILENCIO PREDETERMINADO para las horas habituales.",
+		OptedOut:         "Cancelaste tu suscripción a Parkn y tus avisos fueron cancelados. No recibirás más mensajes. Responde START para suscribirte de nuevo.",
+		OptedIn:          "Te suscribiste de nuevo a Parkn. Envía una foto de un letrero de limpieza de calles para crear un aviso. Responde STOP para cancelar tu suscripción.",
+		LanguageSet:      "De acuerdo - desde ahora te escribiremos en español.",
+		LanguageOptions:  "Responde IDIOMA seguido de un idioma: {{.Languages}}.",
+		WorkerBusy:       "Estamos leyendo otros letreros en este momento. Vuelve a enviar tu foto en unos minutos.",
+		Throttled:        "Estás enviando mensajes más rápido de lo que podemos atender. Espera un rato antes de enviar más.",
+		ReadingSign:      "Recibido, leyendo tu letrero…",
+		LocationStreet:   "Recibido - te recordaremos mover tu carro en {{.Street}}.",
+		LocationNoStreet: "Recibido - guardamos dónde está estacionado tu carro.",
+		LocationMoved:    "Recibido - tu carro se movió, así que dejamos de enviarte los avisos repetidos{{with .Street}} de {{.}}{{end}}. Envía una foto del letrero de limpieza donde está estacionado ahora para recibir avisos allí.",
+		NoLocation:       "No encontré una ubicación en ese mensaje. Comparte tu ubicación desde Mapas, o escribe el horario como \"2nd & 4th Tuesday 9-11am\".",
+		NothingToLocate:  "Primero envía una foto del letrero de limpieza de calles y luego comparte tu ubicación.",
+		Error:            "Lo sentimos, algo salió mal de nuestro lado. Inténtalo de nuevo en unos minutos.",
+		MissingMedia:     "Envía una foto del letrero de limpieza de calles, escribe el horario como \"2nd & 4th Tuesday 9-11am\", o comparte tu ubicación.",
+		LocationFailed:   "Lo sentimos, no pudimos guardar tu ubicación. Compártela de nuevo en unos minutos.",
+		ConfirmReading:   "Leí esto como {{schedule .Schedule}}, próximo el {{shortDate .MoveBy}}{{with .Street}} para tu carro en {{.}}{{end}}{{.Note}}. Responde SI para guardarlo o envía el horario correcto.",
+		PhotoCombined:    " (leído de las {{.Total}} fotos juntas)",
+		PhotoOne:         " (leído de la foto {{.Index}} de {{.Total}})",
+		CancelHint:       "Envía LISTA para ver tus avisos y CANCELAR <n> para cancelar uno.",
+
+		Alert:        "¡Mueve tu carro{{with .Nickname}} ({{.}}){{end}}{{with .Street}} en {{.}}{{end}} - la limpieza de calles empieza {{when .}}{{with window .Schedule}} ({{.}}){{end}}! Responde MOVIDO cuando lo hayas hecho, o POSPONER 2h para que te lo recordemos más tarde.",
+		AlertSubject: "tu carro{{with .Nickname}} ({{.}}){{end}}{{with .Street}} en {{.}}{{end}}",
+		Reminder:     "Recordatorio: ¡mueve tu carro{{with .Nickname}} ({{.}}){{end}}{{with .Street}} en {{.}}{{end}} por la limpieza de calles! Responde MOVIDO cuando lo hayas hecho, o POSPONER para que te lo recordemos de nuevo.",
+		Voice:        "Hola, te llama Parkn. La limpieza de calles{{with .Street}} en {{.}}{{end}} empieza el {{spokenDate .MoveBy .HasTime}}. Por favor mueve tu carro{{with .Nickname}}, {{.}},{{end}} antes. Envía MOVIDO cuando lo hayas hecho, o TEXTEAME para recibir los avisos por mensaje.",
+		WhenMinutes:  "en {{.Minutes}} minutos",
+		WhenToday:    "hoy{{if .HasTime}} a las {{clock .MoveBy}}{{end}}",
+		WhenTomorrow: "mañana{{if .HasTime}} a las {{clock .MoveBy}}{{end}}",
+		WhenLater:    "el {{spokenDate .MoveBy .HasTime}}",
+
+		AdviceNoFrequency:   "No encontré un horario de limpieza — asegúrate de que todo el panel del horario salga en la foto, o escríbelo como \"2nd & 4th Tuesday 9-11am\"",
+		AdviceNoText:        "No pude leer texto en esa foto — apunta la cámara de frente al letrero y haz que llene la imagen",
+		AdviceImageTooSmall: "La foto es demasiado pequeña para leerla — envía la foto original en lugar de una miniatura o captura de pantalla",
+		AdviceImageTooDark:  "La foto está muy oscura — enciende el flash o acércate a una luz",
+		AdviceImageBlurry:   "La foto está borrosa — acércate y mantén el teléfono firme",
+	},
+
+	aliases: map[string]string{
+		"AYUDA":    "HELP",
+		"LISTA":    "LIST",
+		"CANCELAR": "CANCEL",
+		"ESTADO":   "STATUS",
+		"SI":       "YES",
+		"SÍ":       "YES",
+		"MOVIDO":   "MOVED",
+		"POSPONER": "SNOOZE",
+		"CORREGIR": "WRONG",
+		"LLAMAME":  "CALL",
+		"LLÁMAME":  "CALL",
+		"TEXTEAME": "TEXT",
+		"TEXTÉAME": "TEXT",
+		"IDIOMA":   "LANG",
+		"NOMBRE":   "NAME",
+		"RECORDAR": "REMIND",
+		"SILENCIO": "QUIET",
+		// not keywords of their own, but read in place of DEFAULT and OFF after RECORDAR and SILENCIO
+		"PREDETERMINADO": "DEFAULT",
+		"NO":             "OFF",
+	},
+	names: []string{"SPANISH", "ESPANOL"},
+	hints: []string{
+		"EL", "LA", "LOS", "LAS", "DE", "DEL", "Y", "ES", "MI", "HOLA", "GRACIAS", "POR", "FAVOR", "QUE", "QUÉ",
+		"CALLE", "LIMPIEZA", "CARRO", "COCHE", "AUTO", "CADA", "PRIMER", "SEGUNDO", "TERCER", "CUARTO", "LUNES",
+		"MARTES", "MIERCOLES", "MIÉRCOLES", "JUEVES", "VIERNES", "SABADO", "SÁBADO", "DOMINGO", "AYUDA", "SI",
+		"SÍ", "LISTA", "CANCELAR",
+	},
+
+	dateLayout:      "02/01/2006",
+	shortDateLayout: "2 Jan",
+	clockLayout:     "15:04",
+	dayLayout:       "Monday 2 de January",
+	dayTimeLayout:   "Monday 2 de January a las 15:04",
+	nameReplacer: newNameReplacer(
+		[12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		[12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		[7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		[7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	),
+	schedule: spanishSchedule,
+	window:   spanishWindow,
+}
+
+// spanishSchedule renders a schedule as "1.er y 3.er lunes de 8:00 a 10:00"
+func spanishSchedule(schedule model.Schedule) string {
+
+	ordinals := make([]string, 0, len(schedule.Occurrences))
+	for _, occurrence := range schedule.Occurrences {
+		ordinals = append(ordinals, spanishOrdinals[occurrence])
+	}
+
+	str := fmt.Sprintf("%s %s", strings.Join(ordinals, " y "), spanishScheduleDays[schedule.DayOfWeek])
+	if schedule.HasWindow {
+		str += " " + spanishWindow(schedule)
+	}
+	return str
+}
+
+// spanishWindow renders a window as "de 8:00 a 10:00"
+func spanishWindow(schedule model.Schedule) string {
+	return fmt.Sprintf("de %d:%02d a %d:%02d", schedule.WindowStart/60, schedule.WindowStart%60, schedule.WindowEnd/60, schedule.WindowEnd%60)
+}
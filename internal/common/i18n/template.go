@@ -0,0 +1,248 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/model"
+)
+
+const (
+	errReadTemplates    = "error while reading message templates"
+	errUnknownLanguage  = "message templates for unknown language"
+	errUnknownKey       = "message template for unknown key"
+	errMissingKey       = "no message template for key"
+	errInvalidTemplate  = "invalid message template"
+	errTemplateFailed   = "message template failed on sample data"
+	errTemplateFallback = "[message unavailable]"
+)
+
+// Data is what message templates can use, such as {{.Street}} or {{when .}}. Alerts fill in the parkn
+// fields and replies only the ones they mention; templates that use a field a message doesn't fill see its zero value.
+type Data struct {
+	// MoveBy is when the car has to be moved by. Schedules without a window move by the start of the day.
+	MoveBy   time.Time
+	Schedule model.Schedule
+	Street   string
+	Nickname string
+	// CancelHint tells the user how to cancel alerts, in their language. It is filled in for every message.
+	CancelHint string
+
+	// At is when a snoozed alert comes back
+	At time.Time
+	// Minutes is how far away MoveBy is, and is only set for the When messages
+	Minutes   int
+	Count     int
+	Index     int
+	Total     int
+	Languages string
+	// Reminders are the user's lead times as they'd type them after REMIND, such as "8pm, 1h"
+	Reminders string
+	// Quiet are the user's quiet hours as they'd type them after QUIET, such as "10pm-7am"
+	Quiet string
+	Note  string
+}
+
+// HasTime reports whether the sign gave a time of day, so MoveBy is more than a date
+func (d Data) HasTime() bool {
+	return d.Schedule.HasWindow
+}
+
+// ParknData returns the fields of a saved alert that templates can use
+func ParknData(parkn model.Parkn) Data {
+	data := Data{
+		MoveBy:   parkn.MoveByDate,
+		Schedule: parkn.Schedule,
+		Nickname: parkn.Nickname,
+	}
+	if parkn.Location != nil {
+		data.Street = parkn.Location.Street
+	}
+	return data
+}
+
+// sampleData is what every template is run against at startup, so one that names a field Data doesn't have fails there
+func sampleData(hasTime bool) Data {
+	return Data{
+		MoveBy: time.Now().Add(20 * time.Minute),
+		Schedule: model.Schedule{
+			DayOfWeek:   1,
+			Occurrences: []int{1, 3},
+			HasWindow:   hasTime,
+			WindowStart: 8 * 60,
+			WindowEnd:   10 * 60,
+		},
+		Street:     "Main St",
+		Nickname:   "Civic",
+		CancelHint: "CANCEL",
+		At:         time.Now(),
+		Minutes:    20,
+		Count:      2,
+		Index:      1,
+		Total:      2,
+		Languages:  "EN (English)",
+		Reminders:  "8pm, 1h",
+		Quiet:      "10pm-7am",
+		Note:       "note",
+	}
+}
+
+func init() {
+	err := Load("")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Load parses every locale's messages as text/template templates, replacing the built-in ones with the overrides
+// in the JSON file at path, such as {"en": {"alert": "Move {{.Nickname}} by {{date .MoveBy}}"}}. An empty path
+// keeps the built-in messages. Every template is run against sample data and nothing changes unless all of them work.
+func Load(path string) error {
+
+	overrides := make(map[string]map[Key]string)
+	if len(path) > 0 {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", errReadTemplates, err)
+		}
+		err = json.Unmarshal(content, &overrides)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", errReadTemplates, path, err)
+		}
+	}
+
+	for tag, messages := range overrides {
+		if _, exists := locales[tag]; !exists {
+			return fmt.Errorf("%s: %s", errUnknownLanguage, tag)
+		}
+		for key := range messages {
+			if _, exists := english.messages[key]; !exists {
+				return fmt.Errorf("%s: %s.%s", errUnknownKey, tag, key)
+			}
+		}
+	}
+
+	parsed := make(map[string]map[Key]*template.Template, len(locales))
+	for tag, locale := range locales {
+		templates, err := locale.parse(overrides[tag])
+		if err != nil {
+			return err
+		}
+		parsed[tag] = templates
+	}
+
+	for tag, locale := range locales {
+		locale.templates = parsed[tag]
+	}
+	return nil
+}
+
+// parse builds the locale's templates from its messages and overrides and checks each one against sample data.
+// Every locale has to define every English key.
+func (l *Locale) parse(overrides map[Key]string) (map[Key]*template.Template, error) {
+
+	templates := make(map[Key]*template.Template, len(english.messages))
+	for key := range english.messages {
+		source, exists := overrides[key]
+		if !exists {
+			source, exists = l.messages[key]
+		}
+		if !exists {
+			return nil, fmt.Errorf("%s: %s.%s", errMissingKey, l.Tag, key)
+		}
+
+		parsed, err := template.New(string(key)).Funcs(l.funcs()).Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s.%s: %w", errInvalidTemplate, l.Tag, key, err)
+		}
+		templates[key] = parsed
+	}
+
+	// the When messages are rendered from inside other templates, so they are checked with the new set in place
+	previous := l.templates
+	l.templates = templates
+	defer func() { l.templates = previous }()
+
+	for key, parsed := range templates {
+		for _, hasTime := range []bool{true, false} {
+			err := parsed.Execute(&bytes.Buffer{}, sampleData(hasTime))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s.%s: %w", errTemplateFailed, l.Tag, key, err)
+			}
+		}
+	}
+
+	return templates, nil
+}
+
+// funcs are the functions templates can call, formatting dates and schedules the way the locale writes them
+func (l *Locale) funcs() template.FuncMap {
+	return template.FuncMap{
+		"date":       l.Date,
+		"shortDate":  l.ShortDate,
+		"clock":      l.Clock,
+		"spokenDate": l.SpokenDate,
+		"schedule":   l.Schedule,
+		"window":     l.Window,
+		"when":       l.when,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+	}
+}
+
+// Text returns the message for key, for messages that don't use any data
+func (l *Locale) Text(key Key) string {
+	return l.Render(key, Data{})
+}
+
+// Render runs the template for key against data. A template that fails at runtime falls back to the built-in
+// English message, since Load already checked every template against sample data.
+func (l *Locale) Render(key Key, data Data) string {
+
+	if key != CancelHint {
+		data.CancelHint = l.Render(CancelHint, data)
+	}
+
+	var rendered bytes.Buffer
+	err := l.templates[key].Execute(&rendered, data)
+	if err == nil {
+		return rendered.String()
+	}
+
+	rendered.Reset()
+	if fallback, parseErr := template.New(string(key)).Funcs(english.funcs()).Parse(english.messages[key]); parseErr == nil {
+		if fallback.Execute(&rendered, data) == nil {
+			return rendered.String()
+		}
+	}
+	return errTemplateFallback
+}
+
+// when says how far away MoveBy is: in minutes when it's within the hour, then today, tomorrow or the date
+func (l *Locale) when(data Data) string {
+
+	loc, _ := time.LoadLocation("EST")
+	now := time.Now().In(loc)
+	moveBy := data.MoveBy.In(loc)
+
+	until := moveBy.Sub(now)
+	if data.HasTime() && until > 0 && until < time.Hour {
+		data.Minutes = int(until.Round(time.Minute) / time.Minute)
+		return l.Render(WhenMinutes, data)
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch time.Date(moveBy.Year(), moveBy.Month(), moveBy.Day(), 0, 0, 0, 0, loc).Sub(today) {
+	case 0:
+		return l.Render(WhenToday, data)
+	case 24 * time.Hour:
+		return l.Render(WhenTomorrow, data)
+	default:
+		return l.Render(WhenLater, data)
+	}
+}
@@ -3,17 +3,59 @@ package config
 import "github.com/spf13/viper"
 
 type Config struct {
-	MongoConnectionString  string `mapstructure:"mongo_connection_string"`
-	MongoAuthMechanism     string `mapstructure:"mongo_auth_mechanism"`
-	MongoAppName           string `mapstructure:"mongo_app_name"`
-	MongoDatabaseName      string `mapstructure:"mongo_database_name"`
-	ServerAddress          string `mapstructure:"server_address"`
-	TerminationGracePeriod int    `mapstructure:"server_grace_period_in_seconds"`
-	AutoAlertPeriod        int    `mapstructure:"auto_alert_period_in_minutes"`
-	TwilioSID              string `mapstructure:"twilio_account_sid"`
-	TwilioNumber           string `mapstructure:"twilio_number"`
-	TwilioToken            string `mapstructure:"twilio_auth_token"`
-	LogLevel               string `mapstructure:"log_level"`
+	MongoConnectionString    string  `mapstructure:"mongo_connection_string"`
+	MongoAuthMechanism       string  `mapstructure:"mongo_auth_mechanism"`
+	MongoAppName             string  `mapstructure:"mongo_app_name"`
+	MongoDatabaseName        string  `mapstructure:"mongo_database_name"`
+	ServerAddress            string  `mapstructure:"server_address"`
+	TerminationGracePeriod   int     `mapstructure:"server_grace_period_in_seconds"`
+	AutoAlertPeriod          int     `mapstructure:"auto_alert_period_in_minutes"`
+	TwilioSID                string  `mapstructure:"twilio_account_sid"`
+	TwilioNumber             string  `mapstructure:"twilio_number"`
+	TwilioToken              string  `mapstructure:"twilio_auth_token"`
+	WhatsAppNumber           string  `mapstructure:"twilio_whatsapp_number"`
+	WhatsAppAlertTemplate    string  `mapstructure:"twilio_whatsapp_alert_template_sid"`
+	WhatsAppReminderTemplate string  `mapstructure:"twilio_whatsapp_reminder_template_sid"`
+	WebhookBaseUrl           string  `mapstructure:"twilio_webhook_base_url"`
+	WebhookSignatureDisabled bool    `mapstructure:"twilio_webhook_signature_validation_disabled"`
+	PendingConfirmationTTL   int     `mapstructure:"pending_confirmation_ttl_in_minutes"`
+	WorkerCount              int     `mapstructure:"worker_count"`
+	WorkerQueueSize          int     `mapstructure:"worker_queue_size"`
+	WorkerJobTimeout         int     `mapstructure:"worker_job_timeout_in_seconds"`
+	InboundLogRetention      int     `mapstructure:"inbound_log_retention_in_days"`
+	SentAlertRetention       int     `mapstructure:"sent_alert_retention_in_days"`
+	SnoozeDefault            int     `mapstructure:"snooze_default_in_minutes"`
+	SnoozeMax                int     `mapstructure:"snooze_max_in_hours"`
+	AlertLeadTimes           string  `mapstructure:"alert_lead_times"`
+	QuietHours               string  `mapstructure:"quiet_hours"`
+	GeocoderUrl              string  `mapstructure:"geocoder_reverse_url"`
+	MessageTemplatesPath     string  `mapstructure:"message_templates_path"`
+	VoiceMaxAttempts         int     `mapstructure:"voice_call_max_attempts"`
+	VoiceRetryDelay          int     `mapstructure:"voice_call_retry_delay_in_minutes"`
+	TextMaxAttempts          int     `mapstructure:"text_max_attempts"`
+	TextRetryDelay           int     `mapstructure:"text_retry_delay_in_minutes"`
+	AlertRetryMaxAttempts    int     `mapstructure:"alert_retry_max_attempts"`
+	AlertRetryBaseDelay      int     `mapstructure:"alert_retry_base_delay_in_seconds"`
+	AlertRetryMaxDelay       int     `mapstructure:"alert_retry_max_delay_in_minutes"`
+	RateLimitWindow          int     `mapstructure:"rate_limit_window_in_minutes"`
+	RateLimitSenderMessages  int     `mapstructure:"rate_limit_sender_messages"`
+	RateLimitSenderMedia     int     `mapstructure:"rate_limit_sender_media"`
+	RateLimitGlobalMedia     int     `mapstructure:"rate_limit_global_media"`
+	RateLimitStrikesToBlock  int     `mapstructure:"rate_limit_strikes_to_block"`
+	RateLimitStrikeWindow    int     `mapstructure:"rate_limit_strike_window_in_hours"`
+	RateLimitBlockDuration   int     `mapstructure:"rate_limit_block_in_hours"`
+	LogLevel                 string  `mapstructure:"log_level"`
+	AdminApiKey              string  `mapstructure:"admin_api_key"`
+	OcrCacheTTL              int     `mapstructure:"ocr_cache_ttl_in_hours"`
+	OcrCachePerceptualHash   bool    `mapstructure:"ocr_cache_perceptual_hash_enabled"`
+	OcrCacheMaxHashDist      int     `mapstructure:"ocr_cache_perceptual_hash_max_distance"`
+	ArchiveStore             string  `mapstructure:"archive_store"`
+	ArchivePath              string  `mapstructure:"archive_path"`
+	ArchiveRetention         int     `mapstructure:"archive_retention_in_days"`
+	ImageMinWidth            int     `mapstructure:"image_min_width"`
+	ImageMinHeight           int     `mapstructure:"image_min_height"`
+	ImageMinBrightness       float64 `mapstructure:"image_min_brightness"`
+	ImageMinSharpness        float64 `mapstructure:"image_min_sharpness"`
 }
 
 func Init(path string) (*Config, error) {
@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"github.com/willtowle1/parkn/internal/service"
+)
+
+const (
+	errGetArchive        = "error while getting archive"
+	errArchiveMissing    = "archive not found"
+	errInvalidMediaIndex = "media index must be a number"
+	errCorrectionReport  = "error while getting correction report"
+	errInvalidReportArg  = "days and limit must be positive numbers"
+	errListBlocked       = "error while listing blocked senders"
+	errUnblock           = "error while unblocking sender"
+	errNotBlocked        = "sender is not blocked"
+	errConsentHistory    = "error while getting consent history"
+	errListDeadLetters   = "error while listing dead-lettered alerts"
+	errRedrive           = "error while re-driving dead-lettered alert"
+	errDeadLetterMissing = "no dead-lettered alert with that id"
+	errDeadLetterExpired = "the sweep this alert was for has already started, so it can't be re-driven"
+
+	codeBadRequest = "bad_request"
+	codeNotFound   = "not_found"
+	codeConflict   = "conflict"
+	codeInternal   = "internal"
+
+	defaultMediaContentType = "application/octet-stream"
+
+	defaultReportDays  = "30"
+	defaultReportLimit = "20"
+)
+
+type IOcrCacheStats interface {
+	Stats() model.OcrCacheStats
+}
+
+type IArchiver interface {
+	GetByParknID(ctx context.Context, parknID string) (model.SubmissionArchive, error)
+	GetMedia(ctx context.Context, parknID string, index int) ([]byte, string, error)
+}
+
+type ICorrectionReport interface {
+	MostCorrectedPhrases(ctx context.Context, since time.Time, limit int) ([]model.CorrectedPhrase, error)
+}
+
+type IBlocklist interface {
+	ListBlocked(ctx context.Context) ([]model.BlockedSender, error)
+	Unblock(ctx context.Context, phoneNumber string) error
+}
+
+type IConsentHistory interface {
+	History(ctx context.Context, phoneNumber string) ([]model.ConsentEvent, error)
+}
+
+type IDeadLetters interface {
+	DeadLetters(ctx context.Context) ([]model.AlertRetry, error)
+	Redrive(ctx context.Context, id string) error
+}
+
+type AdminController struct {
+	logger      logger.Logger
+	apiKey      string
+	ocrCache    IOcrCacheStats
+	archiver    IArchiver
+	corrections ICorrectionReport
+	blocklist   IBlocklist
+	consent     IConsentHistory
+	deadLetters IDeadLetters
+}
+
+func NewAdminController(logger logger.Logger, apiKey string, ocrCache IOcrCacheStats, archiver IArchiver, corrections ICorrectionReport, blocklist IBlocklist, consent IConsentHistory, deadLetters IDeadLetters) *AdminController {
+	return &AdminController{
+		logger:      logger,
+		apiKey:      apiKey,
+		ocrCache:    ocrCache,
+		archiver:    archiver,
+		corrections: corrections,
+		blocklist:   blocklist,
+		consent:     consent,
+		deadLetters: deadLetters,
+	}
+}
+
+func (c *AdminController) RegisterRoutes(router gin.IRouter) {
+	route := router.Group("/v1/admin", AdminAuth(c.apiKey))
+	route.Handle(http.MethodGet, "/ocr-cache/stats", c.getOcrCacheStats)
+	route.Handle(http.MethodGet, "/archives/:parknId", c.getArchive)
+	route.Handle(http.MethodGet, "/archives/:parknId/media/:index", c.getArchiveMedia)
+	route.Handle(http.MethodGet, "/corrections/phrases", c.getCorrectedPhrases)
+	route.Handle(http.MethodGet, "/blocked", c.getBlocked)
+	route.Handle(http.MethodDelete, "/blocked/:phoneNumber", c.unblock)
+	route.Handle(http.MethodGet, "/consent/:phoneNumber", c.getConsentHistory)
+	route.Handle(http.MethodGet, "/alerts/dead-letters", c.getDeadLetters)
+	route.Handle(http.MethodPost, "/alerts/dead-letters/:id/redrive", c.redrive)
+}
+
+// getOcrCacheStats reports the cache counters of the replica that serves the request. They are kept in memory, so they
+// start from zero whenever the process restarts and do not add up across replicas.
+func (c *AdminController) getOcrCacheStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.ocrCache.Stats())
+}
+
+func (c *AdminController) getArchive(ctx *gin.Context) {
+
+	parknID := ctx.Param("parknId")
+
+	archive, err := c.archiver.GetByParknID(ctx, parknID)
+	if err != nil {
+		c.abortWithArchiveError(ctx, parknID, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, archive)
+}
+
+func (c *AdminController) getArchiveMedia(ctx *gin.Context) {
+
+	parknID := ctx.Param("parknId")
+
+	index, err := strconv.Atoi(ctx.Param("index"))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, errs.NewApiError(http.StatusBadRequest, codeBadRequest, errInvalidMediaIndex, "index", ctx.Param("index")))
+		return
+	}
+
+	media, contentType, err := c.archiver.GetMedia(ctx, parknID, index)
+	if err != nil {
+		c.abortWithArchiveError(ctx, parknID, err)
+		return
+	}
+
+	if len(contentType) == 0 {
+		contentType = defaultMediaContentType
+	}
+
+	ctx.Data(http.StatusOK, contentType, media)
+}
+
+// getCorrectedPhrases reports the phrases users corrected most over the last ?days, limited to ?limit phrases
+func (c *AdminController) getCorrectedPhrases(ctx *gin.Context) {
+
+	days, daysErr := strconv.Atoi(ctx.DefaultQuery("days", defaultReportDays))
+	limit, limitErr := strconv.Atoi(ctx.DefaultQuery("limit", defaultReportLimit))
+	if daysErr != nil || limitErr != nil || days < 1 || limit < 1 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, errs.NewApiError(http.StatusBadRequest, codeBadRequest, errInvalidReportArg, "days", ctx.Query("days"), "limit", ctx.Query("limit")))
+		return
+	}
+
+	since := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	report, err := c.corrections.MostCorrectedPhrases(ctx, since, limit)
+	if err != nil {
+		c.logger.Error(ctx, errCorrectionReport, err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errs.NewApiError(http.StatusInternalServerError, codeInternal, errCorrectionReport))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// getBlocked lists the senders currently blocked for going over the rate limits
+func (c *AdminController) getBlocked(ctx *gin.Context) {
+
+	blocked, err := c.blocklist.ListBlocked(ctx)
+	if err != nil {
+		c.logger.Error(ctx, errListBlocked, err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errs.NewApiError(http.StatusInternalServerError, codeInternal, errListBlocked))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blocked)
+}
+
+func (c *AdminController) unblock(ctx *gin.Context) {
+
+	phoneNumber := ctx.Param("phoneNumber")
+
+	err := c.blocklist.Unblock(ctx, phoneNumber)
+	if errors.Is(err, service.ErrSenderNotBlocked) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, errs.NewApiError(http.StatusNotFound, codeNotFound, errNotBlocked, "phoneNumber", phoneNumber))
+		return
+	}
+	if err != nil {
+		c.logger.Error(ctx, errUnblock, err, "phoneNumber", phoneNumber)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errs.NewApiError(http.StatusInternalServerError, codeInternal, errUnblock, "phoneNumber", phoneNumber))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// getConsentHistory lists every opt-out and opt-in recorded for the phone number, oldest first
+func (c *AdminController) getConsentHistory(ctx *gin.Context) {
+
+	phoneNumber := ctx.Param("phoneNumber")
+
+	history, err := c.consent.History(ctx, phoneNumber)
+	if err != nil {
+		c.logger.Error(ctx, errConsentHistory, err, "phoneNumber", phoneNumber)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errs.NewApiError(http.StatusInternalServerError, codeInternal, errConsentHistory, "phoneNumber", phoneNumber))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
+
+// getDeadLetters lists the alerts that ran out of retries, with the error from their last attempt
+func (c *AdminController) getDeadLetters(ctx *gin.Context) {
+
+	dead, err := c.deadLetters.DeadLetters(ctx)
+	if err != nil {
+		c.logger.Error(ctx, errListDeadLetters, err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errs.NewApiError(http.StatusInternalServerError, codeInternal, errListDeadLetters))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dead)
+}
+
+// redrive puts a dead-lettered alert back on the retry queue, to be sent on the next auto alert run. Alerts whose
+// sweep has already started are refused with a 409 rather than moved to the next sweep, which has reminders of its own.
+func (c *AdminController) redrive(ctx *gin.Context) {
+
+	id := ctx.Param("id")
+
+	err := c.deadLetters.Redrive(ctx, id)
+	if errors.Is(err, service.ErrRetryNotFound) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, errs.NewApiError(http.StatusNotFound, codeNotFound, errDeadLetterMissing, "id", id))
+		return
+	}
+	if errors.Is(err, service.ErrRetryPastSweep) {
+		ctx.AbortWithStatusJSON(http.StatusConflict, errs.NewApiError(http.StatusConflict, codeConflict, errDeadLetterExpired, "id", id))
+		return
+	}
+	if err != nil {
+		c.logger.Error(ctx, errRedrive, err, "id", id)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errs.NewApiError(http.StatusInternalServerError, codeInternal, errRedrive, "id", id))
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+func (c *AdminController) abortWithArchiveError(ctx *gin.Context, parknID string, err error) {
+	if errors.Is(err, service.ErrArchiveNotFound) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, errs.NewApiError(http.StatusNotFound, codeNotFound, errArchiveMissing, "parknId", parknID))
+		return
+	}
+	c.logger.Error(ctx, errGetArchive, err, "parknId", parknID)
+	ctx.AbortWithStatusJSON(http.StatusInternalServerError, errs.NewApiError(http.StatusInternalServerError, codeInternal, errGetArchive, "parknId", parknID))
+}
@@ -5,91 +5,441 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/twilio/twilio-go/twiml"
+	"github.com/willtowle1/parkn/internal/common/i18n"
 	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"github.com/willtowle1/parkn/internal/service"
 )
 
 const (
-	msgCreateParknSuccess = "parkn alert created successfully"
+	// Twilio attaches at most 10 media items to a single message
+	maxMedia = 10
+
+	msgConfirmRequested = "asked user to confirm reading"
+	msgLocationSaved    = "saved shared location"
+	msgCarMoved         = "car moved, repeating alert stopped"
+	msgLanguageDetected = "detected language from first message"
+	msgBlockedSender    = "ignored message from blocked sender"
+	msgSuppressedSender = "ignored message from sender who opted out"
 
 	errCreateParkn          = "error while creating parkn alert"
 	errMissingPhoneNumber   = "no phone number found in context"
-	errMissingMedia         = "no media found in message"
+	errMissingMedia         = "no media or schedule found in message"
 	errMissingImageEncoding = "no image encoding found in context"
+	errWorkerBusy           = "background worker queue is full"
+	errSendReply            = "error while sending reply"
+	errSaveLocation         = "error while saving location"
+	errCallStatus           = "error while handling call status"
+	errMessageStatus        = "error while handling message status"
+	errLanguage             = "error while getting language"
+	errRateLimit            = "error while checking rate limits, allowing message"
+	errSuppression          = "error while checking suppression list"
+)
+
+var (
+	// what to tell a sender whose message was turned away by the rate limits
+	rateLimitReplies = map[string]i18n.Key{
+		service.RateThrottled: i18n.Throttled,
+		service.RateBusy:      i18n.WorkerBusy,
+	}
+
+	// content types phones use for shared location cards
+	vCardContentTypes = map[string]bool{
+		"text/vcard":     true,
+		"text/x-vcard":   true,
+		"text/directory": true,
+	}
 )
 
 type IService interface {
-	CreateParkn(ctx context.Context, phoneNumber, mediaUrl string) (string, error)
+	CreateParkn(ctx context.Context, phoneNumber, channel string, mediaUrls []string, shared service.SharedLocation) (service.Reading, error)
+	CreateParknFromSchedule(ctx context.Context, phoneNumber, channel, schedule string, shared service.SharedLocation) (service.Reading, error)
+	SaveLocation(ctx context.Context, phoneNumber string, shared service.SharedLocation) (string, error)
+	ConfirmParkn(ctx context.Context, phoneNumber string) (time.Time, error)
+	ListParkns(ctx context.Context, phoneNumber string) ([]model.Parkn, error)
+	CancelParkns(ctx context.Context, phoneNumber string, index int) (int64, error)
+	CorrectParkn(ctx context.Context, phoneNumber, text string) (service.Reading, error)
+	NameParkn(ctx context.Context, phoneNumber, nickname string) error
+	AcknowledgeParkn(ctx context.Context, parkn model.Parkn) error
+}
+
+type IWorker interface {
+	Submit(job service.Job) bool
+}
+
+type IMessenger interface {
+	Send(ctx context.Context, phoneNumber, channel, body string) error
+}
+
+type IAlertLog interface {
+	Acknowledge(ctx context.Context, phoneNumber string) (model.SentAlert, error)
+	Snooze(ctx context.Context, phoneNumber string, duration time.Duration) (time.Time, error)
+	CallEnded(ctx context.Context, callSid, callStatus string) error
+	MessageStatus(ctx context.Context, messageSid, messageStatus, errorCode string) error
+}
+
+type IPreferences interface {
+	Get(ctx context.Context, phoneNumber string) (model.Preferences, error)
+	SetDelivery(ctx context.Context, phoneNumber, delivery string) error
+	SetLanguage(ctx context.Context, phoneNumber, language string) error
+	SetLeadTimes(ctx context.Context, phoneNumber string, leadTimes []model.LeadTime) error
+	SetQuietHours(ctx context.Context, phoneNumber string, quiet *model.QuietHours) error
+}
+
+type IConsent interface {
+	IsSuppressed(ctx context.Context, phoneNumber string) (bool, error)
+	OptOut(ctx context.Context, phoneNumber, channel, keyword, messageSid string) error
+	OptIn(ctx context.Context, phoneNumber, channel, keyword, messageSid string) error
+}
+
+type IRateLimiter interface {
+	Check(ctx context.Context, phoneNumber string, numMedia int) (service.RateVerdict, error)
 }
 
 type Controller struct {
-	logger  logger.Logger
-	service IService
+	logger      logger.Logger
+	service     IService
+	alertLog    IAlertLog
+	prefs       IPreferences
+	worker      IWorker
+	messenger   IMessenger
+	inboundLog  IInboundLog
+	rateLimiter IRateLimiter
+	consent     IConsent
+	keywords    map[string]keywordHandler
 }
 
-func NewController(logger logger.Logger, service IService) *Controller {
-	return &Controller{
-		logger:  logger,
-		service: service,
+func NewController(logger logger.Logger, service IService, alertLog IAlertLog, prefs IPreferences, worker IWorker, messenger IMessenger, inboundLog IInboundLog, rateLimiter IRateLimiter, consent IConsent) *Controller {
+	controller := &Controller{
+		logger:      logger,
+		service:     service,
+		alertLog:    alertLog,
+		prefs:       prefs,
+		worker:      worker,
+		messenger:   messenger,
+		inboundLog:  inboundLog,
+		rateLimiter: rateLimiter,
+		consent:     consent,
 	}
+	controller.registerKeywords()
+	return controller
+}
+
+func (c *Controller) RegisterRoutes(router gin.IRouter, webhookAuth gin.HandlerFunc) {
+	route := router.Group("/v1", webhookAuth)
+	route.Handle(http.MethodPost, "/parkn/sms", Idempotent(c.logger, c.inboundLog), c.createParkn)
+	route.Handle(http.MethodPost, "/parkn/voice/status", c.voiceStatus)
+	route.Handle(http.MethodPost, "/parkn/sms/status", c.messageStatus)
+}
+
+// voiceStatus receives Twilio's status callback for alert calls so unanswered ones can be retried
+func (c *Controller) voiceStatus(ctx *gin.Context) {
+
+	callSid := ctx.PostForm("CallSid")
+	callStatus := ctx.PostForm("CallStatus")
+	if len(callSid) == 0 {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+
+	err := c.alertLog.CallEnded(ctx, callSid, callStatus)
+	if err != nil {
+		c.logger.Error(ctx, errCallStatus, err, "callSid", callSid)
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
 }
 
-func (c *Controller) RegisterRoutes(router gin.IRouter) {
-	route := router.Group("/v1")
-	route.Handle(http.MethodPost, "/parkn/sms", c.createParkn)
+// messageStatus receives Twilio's status callback for texts so every delivery status is recorded against its
+// alert and undelivered ones can be retried
+func (c *Controller) messageStatus(ctx *gin.Context) {
+
+	messageSid := ctx.PostForm("MessageSid")
+	messageStatus := ctx.PostForm("MessageStatus")
+	if len(messageSid) == 0 || len(messageStatus) == 0 {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+
+	err := c.alertLog.MessageStatus(ctx, messageSid, messageStatus, ctx.PostForm("ErrorCode"))
+	if err != nil {
+		c.logger.Error(ctx, errMessageStatus, err, "messageSid", messageSid)
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
 }
 
 func (c *Controller) createParkn(ctx *gin.Context) {
 
 	ctx.Header("Content-Type", "text/xml")
 
-	phoneNumber := ctx.PostForm("From")
+	// WhatsApp senders arrive as "whatsapp:+1..." and are stored by phone number with the channel kept alongside
+	phoneNumber, channel := model.SplitAddress(ctx.PostForm("From"))
 	if len(phoneNumber) == 0 {
 		err := errors.New(errMissingPhoneNumber)
 		c.logger.Error(ctx, errCreateParkn, err)
-		message := c.createErrorMessage(errCreateParkn, errMissingPhoneNumber)
+		message := c.createErrorMessage(i18n.Get(i18n.Default), i18n.Error)
 		ctx.String(http.StatusBadRequest, message)
 		return
 	}
 
-	mediaUrl := ctx.PostForm("MediaUrl0")
-	if len(mediaUrl) == 0 {
+	body := strings.TrimSpace(ctx.PostForm("Body"))
+
+	// STOP and START are honored before anything else, including the rate limits
+	suppressed := c.isSuppressed(ctx, phoneNumber)
+	if c.handleConsent(ctx, phoneNumber, channel, body, suppressed) {
+		return
+	}
+	if suppressed {
+		c.logger.Info(ctx, msgSuppressedSender, "phoneNumber", phoneNumber)
+		ctx.String(http.StatusOK, emptyTwiml)
+		return
+	}
+
+	mediaUrls, vCardUrls := c.attachments(ctx)
+
+	verdict := c.checkRate(ctx, phoneNumber, len(mediaUrls))
+	if verdict.Decision == service.RateBlocked {
+		c.logger.Info(ctx, msgBlockedSender, "phoneNumber", phoneNumber)
+		ctx.String(http.StatusOK, emptyTwiml)
+		return
+	}
+
+	locale := c.locale(ctx, phoneNumber, body)
+	if verdict.Decision != service.RateAllowed {
+		reply := emptyTwiml
+		if verdict.Notify {
+			reply = c.createReplyMessage(locale.Text(rateLimitReplies[verdict.Decision]))
+		}
+		ctx.String(http.StatusOK, reply)
+		return
+	}
+
+	shared := service.SharedLocation{
+		Text:      body,
+		VCardUrls: vCardUrls,
+		Latitude:  ctx.PostForm("Latitude"),
+		Longitude: ctx.PostForm("Longitude"),
+		Address:   ctx.PostForm("Address"),
+	}
+
+	if len(mediaUrls) > 0 {
+		// downloading and reading photos can outlast Twilio's webhook timeout, so the outcome is texted back later
+		messageSid := ctx.PostForm("MessageSid")
+		accepted := c.worker.Submit(func(jobCtx context.Context) {
+			c.readMediaInBackground(jobCtx, messageSid, phoneNumber, channel, locale, mediaUrls, shared)
+		})
+		if !accepted {
+			c.logger.Error(ctx, errCreateParkn, errors.New(errWorkerBusy), "phoneNumber", phoneNumber)
+			ctx.String(http.StatusOK, c.createReplyMessage(locale.Text(i18n.WorkerBusy)))
+			return
+		}
+		ctx.String(http.StatusOK, c.createReplyMessage(locale.Text(i18n.ReadingSign)))
+		return
+	}
+
+	if c.handleKeyword(ctx, phoneNumber, locale, body) {
+		return
+	}
+
+	hasLocation := len(vCardUrls) > 0 || len(shared.Latitude) > 0
+	if len(body) == 0 && !hasLocation {
 		err := errors.New(errMissingMedia)
 		c.logger.Error(ctx, errCreateParkn, err)
-		message := c.createErrorMessage(errCreateParkn, errMissingMedia)
+		message := c.createErrorMessage(locale, i18n.MissingMedia)
 		ctx.String(http.StatusBadRequest, message)
 		return
 	}
 
-	moveByDate, err := c.service.CreateParkn(ctx, phoneNumber, mediaUrl)
+	// a location shared on its own, as WhatsApp and iOS send it, belongs to the latest reading or alert
+	if len(body) == 0 {
+		ctx.String(http.StatusOK, c.createReplyMessage(c.saveLocation(ctx, phoneNumber, locale, shared)))
+		return
+	}
+
+	// without a photo the body is treated as a typed schedule such as "2nd & 4th Tuesday 9-11am"
+	reading, err := c.service.CreateParknFromSchedule(ctx, phoneNumber, channel, body, shared)
+	reply, status := c.readingReply(ctx, locale, reading, 0, err)
+	ctx.String(status, c.createReplyMessage(reply))
+}
+
+// isSuppressed reports whether the sender opted out. Messages are processed when the list can't be read, and
+// anything sent to the sender is still checked against it again.
+func (c *Controller) isSuppressed(ctx context.Context, phoneNumber string) bool {
+	suppressed, err := c.consent.IsSuppressed(ctx, phoneNumber)
+	if err != nil {
+		c.logger.Error(ctx, errSuppression, err, "phoneNumber", phoneNumber)
+		return false
+	}
+	return suppressed
+}
+
+// checkRate applies the rate limits to the message. Messages are let through when the limits can't be checked.
+func (c *Controller) checkRate(ctx context.Context, phoneNumber string, numMedia int) service.RateVerdict {
+	verdict, err := c.rateLimiter.Check(ctx, phoneNumber, numMedia)
+	if err != nil {
+		c.logger.Error(ctx, errRateLimit, err, "phoneNumber", phoneNumber)
+		return service.RateVerdict{Decision: service.RateAllowed}
+	}
+	return verdict
+}
+
+// locale returns the user's language. Users who never picked one get the language their first recognizable
+// message was written in, which is kept for every later message and alert.
+func (c *Controller) locale(ctx context.Context, phoneNumber, body string) *i18n.Locale {
+
+	preferences, err := c.prefs.Get(ctx, phoneNumber)
+	if err != nil {
+		c.logger.Error(ctx, errLanguage, err, "phoneNumber", phoneNumber)
+		return i18n.Get(preferences.Language)
+	}
+	if len(preferences.Language) > 0 {
+		return i18n.Get(preferences.Language)
+	}
+
+	language, detected := i18n.Detect(body)
+	if !detected {
+		return i18n.Get(i18n.Default)
+	}
+
+	err = c.prefs.SetLanguage(ctx, phoneNumber, language)
+	if err != nil {
+		c.logger.Error(ctx, errLanguage, err, "phoneNumber", phoneNumber)
+	}
+	c.logger.Info(ctx, msgLanguageDetected, "phoneNumber", phoneNumber, "language", language)
+	return i18n.Get(language)
+}
+
+func (c *Controller) readMediaInBackground(ctx context.Context, messageSid, phoneNumber, channel string, locale *i18n.Locale, mediaUrls []string, shared service.SharedLocation) {
+
+	reading, err := c.service.CreateParkn(ctx, phoneNumber, channel, mediaUrls, shared)
+	reply, _ := c.readingReply(ctx, locale, reading, len(mediaUrls), err)
+
+	// sent moments after the user's message, so this is inside WhatsApp's session window
+	err = c.messenger.Send(ctx, phoneNumber, channel, reply)
+	if err != nil {
+		c.logger.Error(ctx, errSendReply, err, "phoneNumber", phoneNumber)
+	}
+
+	if len(messageSid) > 0 {
+		err = c.inboundLog.RecordResult(ctx, messageSid, reply)
+		if err != nil {
+			c.logger.Error(ctx, errInboundLog, err, "messageSid", messageSid)
+		}
+	}
+}
+
+func (c *Controller) saveLocation(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, shared service.SharedLocation) string {
+
+	street, err := c.service.SaveLocation(ctx, phoneNumber, shared)
+	if errors.Is(err, service.ErrNoLocation) {
+		return locale.Text(i18n.NoLocation)
+	}
+	if errors.Is(err, service.ErrNothingToLocate) {
+		return locale.Text(i18n.NothingToLocate)
+	}
+	if errors.Is(err, service.ErrCarMoved) {
+		c.logger.Info(ctx, msgCarMoved, "phoneNumber", phoneNumber)
+		return locale.Render(i18n.LocationMoved, i18n.Data{Street: street})
+	}
+	if err != nil {
+		c.logger.Error(ctx, errSaveLocation, err)
+		return locale.Text(i18n.LocationFailed)
+	}
+
+	c.logger.Info(ctx, msgLocationSaved, "phoneNumber", phoneNumber)
+	if len(street) == 0 {
+		return locale.Text(i18n.LocationNoStreet)
+	}
+	return locale.Render(i18n.LocationStreet, i18n.Data{Street: street})
+}
+
+// attachments collects MediaUrl0..N, bounded by NumMedia when Twilio sends it, and splits shared
+// location vCards from the photos using MediaContentType0..N
+func (c *Controller) attachments(ctx *gin.Context) ([]string, []string) {
+
+	numMedia, err := strconv.Atoi(ctx.PostForm("NumMedia"))
+	if err != nil {
+		numMedia = maxMedia
+	}
+
+	mediaUrls := make([]string, 0)
+	vCardUrls := make([]string, 0)
+	for i := 0; i < min(numMedia, maxMedia); i++ {
+		mediaUrl := ctx.PostForm(fmt.Sprintf("MediaUrl%d", i))
+		if len(mediaUrl) == 0 {
+			break
+		}
+
+		contentType := strings.ToLower(ctx.PostForm(fmt.Sprintf("MediaContentType%d", i)))
+		if vCardContentTypes[contentType] {
+			vCardUrls = append(vCardUrls, mediaUrl)
+			continue
+		}
+		mediaUrls = append(mediaUrls, mediaUrl)
+	}
+
+	return mediaUrls, vCardUrls
+}
+
+// readingReply is the reply to a submission: a request to confirm what was read, advice on how
+// to retake the photo, or an error. It also returns the status code for webhook replies.
+func (c *Controller) readingReply(ctx context.Context, locale *i18n.Locale, reading service.Reading, numMedia int, err error) (string, int) {
+
+	var advice *service.Advice
+	if errors.As(err, &advice) {
+		c.logger.Info(ctx, errCreateParkn, "reason", advice.Reason)
+		return locale.Text(advice.Key), http.StatusOK
+	}
 
 	if err != nil {
 		c.logger.Error(ctx, errCreateParkn, err)
-		message := c.createErrorMessage(errCreateParkn, err.Error())
-		ctx.String(http.StatusInternalServerError, message)
-		return
+		return locale.Text(i18n.Error), http.StatusInternalServerError
 	}
 
-	message := c.createSuccessMessage(msgCreateParknSuccess, moveByDate)
+	c.logger.Info(ctx, msgConfirmRequested, "schedule", reading.Schedule.String())
+	return c.confirmText(locale, reading, photoNote(locale, reading.Photo, numMedia)), http.StatusOK
+}
 
-	c.logger.Info(ctx, msgCreateParknSuccess, "moveByDate", moveByDate)
-	ctx.String(http.StatusOK, message)
+// createErrorMessage replies with a failure from the catalog. What went wrong is logged, never sent to the user.
+func (c *Controller) createErrorMessage(locale *i18n.Locale, key i18n.Key) string {
+	return c.createReplyMessage(locale.Text(key))
 }
 
-func (c *Controller) createErrorMessage(msg, errString string) string {
+func (c *Controller) createReplyMessage(reply string) string {
 	message := &twiml.MessagingMessage{
-		Body: fmt.Sprintf("Error - %s: %s", msg, errString),
+		Body: reply,
 	}
 	res, _ := twiml.Messages([]twiml.Element{message})
 	return res
 }
 
-func (c *Controller) createSuccessMessage(msg, moveByDate string) string {
-	message := &twiml.MessagingMessage{
-		Body: fmt.Sprintf("Success - %s. You will be alerted to move your car by %s", msg, moveByDate),
+func (c *Controller) confirmText(locale *i18n.Locale, reading service.Reading, note string) string {
+	return locale.Render(i18n.ConfirmReading, i18n.Data{
+		MoveBy:   reading.NextOn,
+		Schedule: reading.Schedule,
+		Street:   reading.Street,
+		Note:     note,
+	})
+}
+
+// photoNote tells the user which photo the schedule came from when they sent more than one
+func photoNote(locale *i18n.Locale, photo, total int) string {
+	if total < 2 {
+		return ""
 	}
-	res, _ := twiml.Messages([]twiml.Element{message})
-	return res
+	if photo == 0 {
+		return locale.Render(i18n.PhotoCombined, i18n.Data{Total: total})
+	}
+	return locale.Render(i18n.PhotoOne, i18n.Data{Index: photo, Total: total})
 }
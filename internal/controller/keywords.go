@@ -0,0 +1,468 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/willtowle1/parkn/internal/common/i18n"
+	"github.com/willtowle1/parkn/internal/model"
+	"github.com/willtowle1/parkn/internal/service"
+)
+
+const (
+	keywordHelp   = "HELP"
+	keywordList   = "LIST"
+	keywordCancel = "CANCEL"
+	keywordStatus = "STATUS"
+	keywordYes    = "YES"
+	keywordMoved  = "MOVED"
+	keywordSnooze = "SNOOZE"
+	keywordWrong  = "WRONG"
+	keywordCall   = "CALL"
+	keywordText   = "TEXT"
+	keywordLang   = "LANG"
+	keywordName   = "NAME"
+	keywordRemind = "REMIND"
+	keywordQuiet  = "QUIET"
+
+	// argDefault after REMIND or QUIET goes back to the settings from config, and argOff after QUIET turns quiet hours off
+	argDefault = "DEFAULT"
+	argOff     = "OFF"
+
+	msgKeywordHandled = "keyword handled"
+	msgConsentHandled = "consent keyword handled"
+
+	errHandleKeyword = "error while handling keyword"
+	errHandleConsent = "error while handling opt-out or opt-in"
+	errCancelOnStop  = "error while canceling alerts after opt-out"
+)
+
+// maxNicknameLength keeps nicknames short enough to fit in an alert
+const maxNicknameLength = 30
+
+// snoozeRegex matches durations such as "2", "2H", "2 HOURS" or "30 MIN". A bare number is in hours.
+var snoozeRegex = regexp.MustCompile(`^(\d{1,4})\s*(M|MIN|MINS|MINUTE|MINUTES|H|HR|HRS|HOUR|HOURS)?$`)
+
+var (
+	// the carrier opt-out and opt-in keywords Twilio honors, which only count when they are the whole message
+	optOutKeywords = map[string]bool{
+		"STOP": true, "STOPALL": true, "UNSUBSCRIBE": true, "CANCEL": true, "END": true, "QUIT": true, "REVOKE": true, "OPTOUT": true,
+	}
+	optInKeywords = map[string]bool{
+		"START": true, "UNSTOP": true, keywordYes: true,
+	}
+)
+
+// keywordHandler returns the reply to a keyword, written in the user's language
+type keywordHandler func(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error)
+
+func (c *Controller) registerKeywords() {
+	c.keywords = map[string]keywordHandler{
+		keywordHelp:   c.help,
+		keywordList:   c.list,
+		keywordCancel: c.cancel,
+		keywordStatus: c.status,
+		keywordYes:    c.confirm,
+		keywordMoved:  c.moved,
+		keywordSnooze: c.snooze,
+		keywordWrong:  c.correct,
+		keywordCall:   c.callMe,
+		keywordText:   c.textMe,
+		keywordLang:   c.language,
+		keywordName:   c.name,
+		keywordRemind: c.remind,
+		keywordQuiet:  c.quiet,
+	}
+}
+
+// handleKeyword replies to a message whose first word is a known keyword, in any language, and reports whether it did
+func (c *Controller) handleKeyword(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, body string) bool {
+
+	fields := strings.Fields(strings.ToUpper(body))
+	if len(fields) == 0 {
+		return false
+	}
+
+	keyword := i18n.Keyword(fields[0])
+	handler, exists := c.keywords[keyword]
+	if !exists {
+		return false
+	}
+
+	reply, err := handler(ctx, phoneNumber, locale, fields[1:])
+	if err != nil {
+		c.logger.Error(ctx, errHandleKeyword, err, "keyword", keyword)
+		ctx.String(http.StatusOK, c.createErrorMessage(locale, i18n.Error))
+		return true
+	}
+
+	c.logger.Info(ctx, msgKeywordHandled, "keyword", keyword)
+	ctx.String(http.StatusOK, c.createReplyMessage(reply))
+	return true
+}
+
+// handleConsent opts the sender out or back in when the whole message is a carrier keyword and reports whether it
+// did. YES only opts back in when the sender opted out, and otherwise confirms a reading.
+func (c *Controller) handleConsent(ctx *gin.Context, phoneNumber, channel, body string, suppressed bool) bool {
+
+	word := strings.ToUpper(strings.TrimSpace(body))
+	// CANCEL is a carrier opt-out, so it opts out in every language rather than only in English
+	optOut := optOutKeywords[word] || i18n.Keyword(word) == keywordCancel
+	optIn := optInKeywords[word] && (suppressed || word != keywordYes)
+	if !optOut && !optIn {
+		return false
+	}
+
+	messageSid := ctx.PostForm("MessageSid")
+	locale := c.locale(ctx, phoneNumber, body)
+
+	var reply string
+	var err error
+	if optOut {
+		reply, err = c.optOut(ctx, phoneNumber, channel, word, messageSid, locale)
+	} else {
+		reply, err = c.optIn(ctx, phoneNumber, channel, word, messageSid, locale)
+	}
+	if err != nil {
+		// a server error makes Twilio retry, and an opt-out must not be lost
+		c.logger.Error(ctx, errHandleConsent, err, "keyword", word)
+		ctx.String(http.StatusInternalServerError, c.createErrorMessage(locale, i18n.Error))
+		return true
+	}
+
+	c.logger.Info(ctx, msgConsentHandled, "keyword", word)
+	ctx.String(http.StatusOK, c.createReplyMessage(reply))
+	return true
+}
+
+// optOut suppresses every message to the sender and cancels their alerts
+func (c *Controller) optOut(ctx *gin.Context, phoneNumber, channel, keyword, messageSid string, locale *i18n.Locale) (string, error) {
+
+	err := c.consent.OptOut(ctx, phoneNumber, channel, keyword, messageSid)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = c.service.CancelParkns(ctx, phoneNumber, 0)
+	if err != nil {
+		c.logger.Error(ctx, errCancelOnStop, err, "phoneNumber", phoneNumber)
+	}
+
+	return locale.Text(i18n.OptedOut), nil
+}
+
+func (c *Controller) optIn(ctx *gin.Context, phoneNumber, channel, keyword, messageSid string, locale *i18n.Locale) (string, error) {
+
+	err := c.consent.OptIn(ctx, phoneNumber, channel, keyword, messageSid)
+	if err != nil {
+		return "", err
+	}
+
+	return locale.Text(i18n.OptedIn), nil
+}
+
+func (c *Controller) help(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+	return locale.Text(i18n.Help), nil
+}
+
+func (c *Controller) list(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	parkns, err := c.service.ListParkns(ctx, phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	if len(parkns) == 0 {
+		return locale.Text(i18n.NoAlerts), nil
+	}
+
+	lines := []string{locale.Text(i18n.ListHeader)}
+	for i, parkn := range parkns {
+		data := i18n.ParknData(parkn)
+		data.Index = i + 1
+		lines = append(lines, locale.Render(i18n.ListItem, data))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (c *Controller) cancel(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	// CANCEL on its own is an opt-out and never gets here, so only canceling one alert is left
+	if len(args) == 0 {
+		return locale.Text(i18n.InvalidCancel), nil
+	}
+	index, err := strconv.Atoi(args[0])
+	if err != nil || index < 1 {
+		return locale.Text(i18n.InvalidCancel), nil
+	}
+
+	_, err = c.service.CancelParkns(ctx, phoneNumber, index)
+	if errors.Is(err, service.ErrInvalidIndex) {
+		return locale.Text(i18n.InvalidCancel), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return locale.Render(i18n.CanceledOne, i18n.Data{Index: index}), nil
+}
+
+func (c *Controller) status(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	parkns, err := c.service.ListParkns(ctx, phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	if len(parkns) == 0 {
+		return locale.Text(i18n.NoAlerts), nil
+	}
+
+	return locale.Render(i18n.NextSweep, i18n.ParknData(parkns[0])), nil
+}
+
+func (c *Controller) confirm(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	moveByDate, err := c.service.ConfirmParkn(ctx, phoneNumber)
+	if errors.Is(err, service.ErrNothingToConfirm) {
+		return locale.Text(i18n.NothingToConfirm), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return locale.Render(i18n.Confirmed, i18n.Data{MoveBy: moveByDate}), nil
+}
+
+func (c *Controller) moved(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	alert, err := c.alertLog.Acknowledge(ctx, phoneNumber)
+	if errors.Is(err, service.ErrNoAlertSent) {
+		return locale.Text(i18n.NoAlertSent), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// the car is moved for this sweep, so later reminders for it aren't needed
+	if alert.Parkn != nil {
+		err = c.service.AcknowledgeParkn(ctx, *alert.Parkn)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return locale.Text(i18n.Moved), nil
+}
+
+func (c *Controller) snooze(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	duration, valid := parseSnooze(args)
+	if !valid {
+		return locale.Text(i18n.InvalidSnooze), nil
+	}
+
+	snoozedUntil, err := c.alertLog.Snooze(ctx, phoneNumber, duration)
+	if errors.Is(err, service.ErrNoAlertSent) {
+		return locale.Text(i18n.NoAlertSent), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return locale.Render(i18n.Snoozed, i18n.Data{At: snoozedUntil}), nil
+}
+
+// parseSnooze reads an optional duration after SNOOZE. Zero means the default snooze.
+func parseSnooze(args []string) (time.Duration, bool) {
+
+	if len(args) == 0 {
+		return 0, true
+	}
+
+	match := snoozeRegex.FindStringSubmatch(strings.Join(args, " "))
+	if match == nil {
+		return 0, false
+	}
+
+	amount, _ := strconv.Atoi(match[1])
+	if amount == 0 {
+		return 0, false
+	}
+
+	if strings.HasPrefix(match[2], "M") {
+		return time.Duration(amount) * time.Minute, true
+	}
+	return time.Duration(amount) * time.Hour, true
+}
+
+func (c *Controller) correct(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	if len(args) == 0 {
+		return locale.Text(i18n.InvalidWrong), nil
+	}
+
+	reading, err := c.service.CorrectParkn(ctx, phoneNumber, strings.Join(args, " "))
+	if errors.Is(err, service.ErrNothingToCorrect) {
+		return locale.Text(i18n.NothingToFix), nil
+	}
+	var advice *service.Advice
+	if errors.As(err, &advice) {
+		return locale.Text(advice.Key), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return locale.Render(i18n.Corrected, i18n.Data{MoveBy: reading.NextOn, Schedule: reading.Schedule}), nil
+}
+
+// name handles NAME <nickname>, keeping the nickname as it was typed rather than in upper case
+func (c *Controller) name(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	if len(args) == 0 {
+		return locale.Text(i18n.InvalidName), nil
+	}
+
+	fields := strings.Fields(ctx.PostForm("Body"))
+	nickname := strings.Join(fields[1:], " ")
+	if utf8.RuneCountInString(nickname) > maxNicknameLength {
+		return locale.Text(i18n.InvalidName), nil
+	}
+
+	err := c.service.NameParkn(ctx, phoneNumber, nickname)
+	if errors.Is(err, service.ErrNothingToName) {
+		return locale.Text(i18n.NothingToName), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return locale.Render(i18n.Named, i18n.Data{Nickname: nickname}), nil
+}
+
+// remind sets when reminders go out before each sweep, such as REMIND 8pm, 1h. On its own it says what they are now.
+func (c *Controller) remind(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	if len(args) == 0 {
+		preferences, err := c.prefs.Get(ctx, phoneNumber)
+		if err != nil {
+			return "", err
+		}
+		return locale.Render(i18n.RemindersCurrent, i18n.Data{Reminders: model.FormatLeadTimes(preferences.LeadTimes)}), nil
+	}
+
+	var leadTimes []model.LeadTime
+	if len(args) > 1 || i18n.Keyword(args[0]) != argDefault {
+		var valid bool
+		leadTimes, valid = model.ParseLeadTimes(strings.Join(args, " "))
+		if !valid {
+			return locale.Text(i18n.InvalidRemind), nil
+		}
+	}
+
+	err := c.prefs.SetLeadTimes(ctx, phoneNumber, leadTimes)
+	if err != nil {
+		return "", err
+	}
+
+	// read back so DEFAULT replies with the lead times from config
+	preferences, err := c.prefs.Get(ctx, phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	return locale.Render(i18n.RemindersSet, i18n.Data{Reminders: model.FormatLeadTimes(preferences.LeadTimes)}), nil
+}
+
+// quiet sets the hours alerts are held, such as QUIET 10pm-7am, or turns them off with QUIET OFF. On its own it says
+// what they are now.
+func (c *Controller) quiet(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	if len(args) == 0 {
+		preferences, err := c.prefs.Get(ctx, phoneNumber)
+		if err != nil {
+			return "", err
+		}
+		return quietReply(locale, preferences.QuietHours, i18n.QuietCurrent), nil
+	}
+
+	var quiet *model.QuietHours
+	if len(args) == 1 && i18n.Keyword(args[0]) == argOff {
+		quiet = &model.QuietHours{}
+	} else if len(args) > 1 || i18n.Keyword(args[0]) != argDefault {
+		parsed, valid := model.ParseQuietHours(strings.Join(args, " "))
+		if !valid {
+			return locale.Text(i18n.InvalidQuiet), nil
+		}
+		quiet = &parsed
+	}
+
+	err := c.prefs.SetQuietHours(ctx, phoneNumber, quiet)
+	if err != nil {
+		return "", err
+	}
+
+	// read back so DEFAULT replies with the quiet hours from config
+	preferences, err := c.prefs.Get(ctx, phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	return quietReply(locale, preferences.QuietHours, i18n.QuietSet), nil
+}
+
+// quietReply describes the user's quiet hours with the given message, or says they are off
+func quietReply(locale *i18n.Locale, quiet *model.QuietHours, key i18n.Key) string {
+	if quiet == nil || quiet.Off() {
+		return locale.Text(i18n.QuietOff)
+	}
+	return locale.Render(key, i18n.Data{Quiet: quiet.String()})
+}
+
+func (c *Controller) callMe(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+	return c.setDelivery(ctx, phoneNumber, locale, args, model.DeliveryVoice, i18n.VoiceOn)
+}
+
+func (c *Controller) textMe(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+	return c.setDelivery(ctx, phoneNumber, locale, args, model.DeliveryText, i18n.VoiceOff)
+}
+
+// setDelivery handles CALL ME and TEXT ME, where ME is optional
+func (c *Controller) setDelivery(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string, delivery string, reply i18n.Key) (string, error) {
+
+	if len(args) > 1 || (len(args) == 1 && args[0] != "ME") {
+		return locale.Text(i18n.InvalidCall), nil
+	}
+
+	err := c.prefs.SetDelivery(ctx, phoneNumber, delivery)
+	if err != nil {
+		return "", err
+	}
+
+	return locale.Text(reply), nil
+}
+
+// language handles LANG <code>, listing the languages on offer when the code is missing or unknown
+func (c *Controller) language(ctx *gin.Context, phoneNumber string, locale *i18n.Locale, args []string) (string, error) {
+
+	if len(args) == 1 {
+		if chosen, found := i18n.Find(args[0]); found {
+			err := c.prefs.SetLanguage(ctx, phoneNumber, chosen.Tag)
+			if err != nil {
+				return "", err
+			}
+			return chosen.Text(i18n.LanguageSet), nil
+		}
+	}
+
+	options := make([]string, 0)
+	for _, option := range i18n.Locales() {
+		options = append(options, fmt.Sprintf("%s (%s)", strings.ToUpper(option.Tag), option.Name))
+	}
+	return locale.Render(i18n.LanguageOptions, i18n.Data{Languages: strings.Join(options, ", ")}), nil
+}
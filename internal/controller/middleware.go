@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	twilioClient "github.com/twilio/twilio-go/client"
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+)
+
+const (
+	adminKeyHeader         = "X-Admin-Key"
+	twilioSignatureHeader  = "X-Twilio-Signature"
+	forwardedProtoHeader   = "X-Forwarded-Proto"
+	forwardedHostHeader    = "X-Forwarded-Host"
+	defaultWebhookProtocol = "https"
+
+	errUnauthorized       = "unauthorized"
+	errAdminKey           = "missing or invalid admin key"
+	errMissingSignature   = "missing twilio signature"
+	errInvalidSignature   = "invalid twilio signature"
+	errParsingWebhookForm = "error while parsing webhook form"
+	errInboundLog         = "error while logging inbound message"
+
+	msgSignatureValidationDisabled = "twilio signature validation is disabled, webhook requests are not authenticated"
+	msgReplayedResponse            = "replayed response for duplicate message"
+	msgDuplicateInProgress         = "duplicate message is still being processed"
+
+	// an empty TwiML response acknowledges a message without replying to it
+	emptyTwiml = `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+)
+
+// AdminAuth rejects requests without a matching admin key. When no key is configured every admin request is rejected.
+func AdminAuth(apiKey string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		provided := ctx.GetHeader(adminKeyHeader)
+		if len(apiKey) == 0 || subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.NewApiError(http.StatusUnauthorized, errUnauthorized, errAdminKey))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// TwilioSignature rejects webhook requests whose X-Twilio-Signature does not match the request signed
+// with the auth token. publicBaseUrl is the externally visible scheme and host Twilio calls, which
+// differs from the request host when running behind a proxy. When empty the forwarded headers are used.
+func TwilioSignature(logger logger.Logger, authToken, publicBaseUrl string, disabled bool) gin.HandlerFunc {
+
+	if disabled {
+		logger.Info(context.Background(), msgSignatureValidationDisabled)
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	validator := twilioClient.NewRequestValidator(authToken)
+	publicBaseUrl = strings.TrimSuffix(publicBaseUrl, "/")
+
+	return func(ctx *gin.Context) {
+
+		signature := ctx.GetHeader(twilioSignatureHeader)
+		if len(signature) == 0 {
+			logger.Error(ctx, errUnauthorized, errors.New(errMissingSignature), "path", ctx.Request.URL.Path)
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		err := ctx.Request.ParseForm()
+		if err != nil {
+			logger.Error(ctx, errParsingWebhookForm, err)
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		params := make(map[string]string, len(ctx.Request.PostForm))
+		for key, values := range ctx.Request.PostForm {
+			if len(values) > 0 {
+				params[key] = values[0]
+			}
+		}
+
+		url := webhookBaseUrl(ctx, publicBaseUrl) + ctx.Request.URL.RequestURI()
+		if !validator.Validate(url, params, signature) {
+			logger.Error(ctx, errUnauthorized, errors.New(errInvalidSignature), "url", url)
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func webhookBaseUrl(ctx *gin.Context, publicBaseUrl string) string {
+	if len(publicBaseUrl) > 0 {
+		return publicBaseUrl
+	}
+
+	proto := ctx.GetHeader(forwardedProtoHeader)
+	if len(proto) == 0 {
+		proto = defaultWebhookProtocol
+	}
+	host := ctx.GetHeader(forwardedHostHeader)
+	if len(host) == 0 {
+		host = ctx.Request.Host
+	}
+	return proto + "://" + host
+}
+
+type IInboundLog interface {
+	Claim(ctx context.Context, message model.InboundMessage) (model.InboundMessage, bool, error)
+	Complete(ctx context.Context, messageSid string, responseCode int, responseType, response string) error
+	Fail(ctx context.Context, messageSid string, responseCode int) error
+	RecordResult(ctx context.Context, messageSid, result string) error
+}
+
+// Idempotent processes each Twilio MessageSid once. Retried deliveries are answered with the
+// response recorded for the first one instead of being handled again.
+func Idempotent(logger logger.Logger, inboundLog IInboundLog) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		messageSid := ctx.PostForm("MessageSid")
+		if len(messageSid) == 0 {
+			ctx.Next()
+			return
+		}
+
+		numMedia, _ := strconv.Atoi(ctx.PostForm("NumMedia"))
+		message := model.InboundMessage{
+			MessageSid:  messageSid,
+			PhoneNumber: ctx.PostForm("From"),
+			Body:        ctx.PostForm("Body"),
+			NumMedia:    numMedia,
+		}
+
+		existing, claimed, err := inboundLog.Claim(ctx, message)
+		if err != nil {
+			// failing open keeps messages flowing when the log is unavailable
+			logger.Error(ctx, errInboundLog, err, "messageSid", messageSid)
+			ctx.Next()
+			return
+		}
+
+		if !claimed {
+			if existing.Status == model.InboundStatusCompleted {
+				logger.Info(ctx, msgReplayedResponse, "messageSid", messageSid)
+				ctx.Data(existing.ResponseCode, existing.ResponseType, []byte(existing.Response))
+			} else {
+				logger.Info(ctx, msgDuplicateInProgress, "messageSid", messageSid)
+				ctx.Data(http.StatusOK, "text/xml", []byte(emptyTwiml))
+			}
+			ctx.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer, body: new(bytes.Buffer)}
+		ctx.Writer = recorder
+
+		ctx.Next()
+
+		// server errors are not replayed so that Twilio's retry gets a fresh attempt
+		if recorder.Status() >= http.StatusInternalServerError {
+			err = inboundLog.Fail(ctx, messageSid, recorder.Status())
+		} else {
+			err = inboundLog.Complete(ctx, messageSid, recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.String())
+		}
+		if err != nil {
+			logger.Error(ctx, errInboundLog, err, "messageSid", messageSid)
+		}
+	}
+}
+
+// responseRecorder keeps a copy of the response body while writing it through
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+	return r.ResponseWriter.WriteString(data)
+}
@@ -0,0 +1,122 @@
+package dal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	errPutBlob    = "error while storing blob"
+	errGetBlob    = "error while reading blob"
+	errDeleteBlob = "error while deleting blob"
+	errInvalidKey = "invalid blob key"
+)
+
+type GridFsBlobStore struct {
+	logger logger.Logger
+	bucket *gridfs.Bucket
+}
+
+func NewGridFsBlobStore(logger logger.Logger, database *mongo.Database, bucketName string) (*GridFsBlobStore, error) {
+	bucket, err := gridfs.NewBucket(database, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, err
+	}
+	return &GridFsBlobStore{
+		logger: logger,
+		bucket: bucket,
+	}, nil
+}
+
+func (s *GridFsBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	err := s.bucket.UploadFromStreamWithID(key, key, bytes.NewReader(data))
+	if err != nil {
+		return errors.New(errPutBlob)
+	}
+	return nil
+}
+
+func (s *GridFsBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	_, err := s.bucket.DownloadToStream(key, buf)
+	if err != nil {
+		return nil, errors.New(errGetBlob)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *GridFsBlobStore) Delete(ctx context.Context, key string) error {
+	err := s.bucket.DeleteContext(ctx, key)
+	if err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return errs.WrapError(errDeleteBlob, err)
+	}
+	return nil
+}
+
+type FileBlobStore struct {
+	logger logger.Logger
+	dir    string
+}
+
+func NewFileBlobStore(logger logger.Logger, dir string) (*FileBlobStore, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, err
+	}
+	return &FileBlobStore{
+		logger: logger,
+		dir:    dir,
+	}, nil
+}
+
+func (s *FileBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, data, 0o640)
+	if err != nil {
+		return errors.New(errPutBlob)
+	}
+	return nil
+}
+
+func (s *FileBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(errGetBlob)
+	}
+	return data, nil
+}
+
+func (s *FileBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errs.WrapError(errDeleteBlob, err)
+	}
+	return nil
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	if len(key) == 0 || filepath.Base(key) != key {
+		return "", errors.New(errInvalidKey)
+	}
+	return filepath.Join(s.dir, key), nil
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 
+	"github.com/willtowle1/parkn/internal/common/errs"
 	"github.com/willtowle1/parkn/internal/common/logger"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -15,6 +17,10 @@ const (
 
 	errCreateOne = "error while creating one"
 	errGet       = "error while getting from collection"
+	errUpdateOne = "error while updating one"
+	errUpsertOne = "error while upserting one"
+	errTakeOne   = "error while finding and deleting one"
+	errModifyOne = "error while finding and updating one"
 )
 
 type Dal[D any] struct {
@@ -31,6 +37,9 @@ func NewRepository[D any](logger logger.Logger, collection mongo.Collection) *Da
 
 func (r *Dal[D]) CreateOne(ctx context.Context, input D) (string, error) {
 	res, err := r.collection.InsertOne(ctx, input)
+	if mongo.IsDuplicateKeyError(err) {
+		return "", errs.ErrDuplicateKey
+	}
 	if err != nil {
 		return "", errors.New(errCreateOne)
 	}
@@ -61,3 +70,84 @@ func (r *Dal[D]) DeleteOne(ctx context.Context, filter interface{}) (int64, erro
 	}
 	return res.DeletedCount, nil
 }
+
+func (r *Dal[D]) DeleteMany(ctx context.Context, filter interface{}) (int64, error) {
+	res, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (r *Dal[D]) UpdateOne(ctx context.Context, filter interface{}, update interface{}) (int64, error) {
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return 0, errors.New(errUpdateOne)
+	}
+	return res.ModifiedCount, nil
+}
+
+// UpsertOne replaces the document matching filter with input, inserting it when none matches
+func (r *Dal[D]) UpsertOne(ctx context.Context, filter interface{}, input D) error {
+	_, err := r.collection.ReplaceOne(ctx, filter, input, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.New(errUpsertOne)
+	}
+	return nil
+}
+
+// UpsertFields applies update to the document matching filter, inserting one built from the filter when none matches
+func (r *Dal[D]) UpsertFields(ctx context.Context, filter interface{}, update interface{}) error {
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return errors.New(errUpsertOne)
+	}
+	return nil
+}
+
+// FindOneAndDelete atomically removes and returns the document matching filter. found is false when none matches.
+func (r *Dal[D]) FindOneAndDelete(ctx context.Context, filter interface{}) (D, bool, error) {
+	var res D
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&res)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return res, false, nil
+	}
+	if err != nil {
+		return res, false, errors.New(errTakeOne)
+	}
+	return res, true, nil
+}
+
+// FindOneAndUpdate atomically updates and returns the first document matching filter in sort order, after the update
+// is applied. found is false when none matches.
+func (r *Dal[D]) FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, sort interface{}) (D, bool, error) {
+	var res D
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if sort != nil {
+		opts.SetSort(sort)
+	}
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&res)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return res, false, nil
+	}
+	if err != nil {
+		return res, false, errors.New(errModifyOne)
+	}
+	return res, true, nil
+}
+
+// FindOneAndUpsert atomically applies update to the document matching filter, inserting one built from the filter
+// when none matches, and returns it after the update. Concurrent inserts can collide on a unique index, which is
+// reported as errs.ErrDuplicateKey so the caller can try again.
+func (r *Dal[D]) FindOneAndUpsert(ctx context.Context, filter interface{}, update interface{}) (D, error) {
+	var res D
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(true)
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&res)
+	if mongo.IsDuplicateKeyError(err) {
+		return res, errs.ErrDuplicateKey
+	}
+	if err != nil {
+		return res, errors.New(errModifyOne)
+	}
+	return res, nil
+}
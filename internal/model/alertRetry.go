@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RetryStatusQueued = "queued"
+	// RetryStatusDead is a retry that ran out of attempts. It stays until an admin re-drives it.
+	RetryStatusDead = "dead"
+)
+
+// AlertRetry is a reminder that failed to send, queued to be tried again with backoff
+type AlertRetry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PhoneNumber string             `bson:"phoneNumber" json:"phoneNumber"`
+	// Parkn is the alert as it was when the reminder failed, and LeadTimes the reminders it stands for
+	Parkn         Parkn     `bson:"parkn" json:"parkn"`
+	LeadTimes     []string  `bson:"leadTimes" json:"leadTimes"`
+	Status        string    `bson:"status" json:"status"`
+	Attempts      int       `bson:"attempts" json:"attempts"`
+	LastError     string    `bson:"lastError" json:"lastError"`
+	NextAttemptAt time.Time `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time `bson:"updatedAt" json:"updatedAt"`
+	DeadAt        time.Time `bson:"deadAt,omitempty" json:"deadAt,omitempty"`
+}
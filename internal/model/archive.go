@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SubmissionArchive struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ParknID       string             `bson:"parknId" json:"parknId"`
+	PhoneNumber   string             `bson:"phoneNumber" json:"phoneNumber"`
+	Media         []ArchivedMedia    `bson:"media" json:"media"`
+	ExtractedText string             `bson:"extractedText" json:"extractedText"`
+	MoveByDate    time.Time          `bson:"moveByDate,omitempty" json:"moveByDate,omitempty"`
+	Error         string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+type ArchivedMedia struct {
+	MediaUrl      string `bson:"mediaUrl" json:"mediaUrl"`
+	MediaKey      string `bson:"mediaKey" json:"-"`
+	ContentType   string `bson:"contentType" json:"contentType"`
+	ExtractedText string `bson:"extractedText" json:"extractedText"`
+	Error         string `bson:"error,omitempty" json:"error,omitempty"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "strings"
+
+const (
+	ChannelSms      = "sms"
+	ChannelWhatsApp = "whatsapp"
+
+	whatsAppPrefix = "whatsapp:"
+)
+
+// SplitAddress separates a Twilio address such as "whatsapp:+15551234567" into the phone number and the
+// channel it was sent on. Addresses without a prefix are SMS.
+func SplitAddress(address string) (string, string) {
+	if phoneNumber, found := strings.CutPrefix(address, whatsAppPrefix); found {
+		return phoneNumber, ChannelWhatsApp
+	}
+	return address, ChannelSms
+}
+
+// JoinAddress is the Twilio address of a phone number on a channel. An empty channel is SMS, which is
+// what records saved before channels existed hold.
+func JoinAddress(phoneNumber, channel string) string {
+	if channel == ChannelWhatsApp {
+		return whatsAppPrefix + phoneNumber
+	}
+	return phoneNumber
+}
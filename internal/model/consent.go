@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ConsentOptOut = "opt_out"
+	ConsentOptIn  = "opt_in"
+)
+
+// Suppression is a phone number that opted out. Nothing is sent to it until it opts back in.
+type Suppression struct {
+	PhoneNumber  string    `bson:"phoneNumber" json:"phoneNumber"`
+	Keyword      string    `bson:"keyword" json:"keyword"`
+	SuppressedAt time.Time `bson:"suppressedAt" json:"suppressedAt"`
+}
+
+// ConsentEvent records an opt-out or opt-in for compliance audits. Events are never deleted.
+type ConsentEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PhoneNumber string             `bson:"phoneNumber" json:"phoneNumber"`
+	Channel     string             `bson:"channel,omitempty" json:"channel,omitempty"`
+	Action      string             `bson:"action" json:"action"`
+	Keyword     string             `bson:"keyword" json:"keyword"`
+	MessageSid  string             `bson:"messageSid,omitempty" json:"messageSid,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Correction pairs the text a schedule was read from with the schedule the user says is right
+type Correction struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PhoneNumber       string             `bson:"phoneNumber" json:"phoneNumber"`
+	ParknID           string             `bson:"parknId" json:"parknId"`
+	ExtractedText     string             `bson:"extractedText" json:"extractedText"`
+	Phrase            string             `bson:"phrase" json:"phrase"`
+	OriginalSchedule  Schedule           `bson:"originalSchedule" json:"originalSchedule"`
+	CorrectionText    string             `bson:"correctionText" json:"correctionText"`
+	CorrectedSchedule Schedule           `bson:"correctedSchedule" json:"correctedSchedule"`
+	CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// CorrectedPhrase is how often a phrase the date sniper matched has been corrected by users
+type CorrectedPhrase struct {
+	Phrase          string    `json:"phrase"`
+	Count           int       `json:"count"`
+	OriginalReads   []string  `json:"originalReads"`
+	CorrectedReads  []string  `json:"correctedReads"`
+	LastCorrectedAt time.Time `json:"lastCorrectedAt"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+const (
+	InboundStatusProcessing = "processing"
+	InboundStatusCompleted  = "completed"
+	// InboundStatusFailed is a message whose processing failed with a server error. Twilio's retry claims it again.
+	InboundStatusFailed = "failed"
+)
+
+type InboundMessage struct {
+	MessageSid   string    `bson:"messageSid" json:"messageSid"`
+	PhoneNumber  string    `bson:"phoneNumber" json:"phoneNumber"`
+	Body         string    `bson:"body" json:"body"`
+	NumMedia     int       `bson:"numMedia" json:"numMedia"`
+	Status       string    `bson:"status" json:"status"`
+	ResponseCode int       `bson:"responseCode,omitempty" json:"responseCode,omitempty"`
+	ResponseType string    `bson:"responseType,omitempty" json:"responseType,omitempty"`
+	Response     string    `bson:"response,omitempty" json:"response,omitempty"`
+	Result       string    `bson:"result,omitempty" json:"result,omitempty"`
+	ReceivedAt   time.Time `bson:"receivedAt" json:"receivedAt"`
+	CompletedAt  time.Time `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	FailedAt     time.Time `bson:"failedAt,omitempty" json:"failedAt,omitempty"`
+}
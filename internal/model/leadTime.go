@@ -0,0 +1,166 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxLeadTimes is how many reminders a user can get before each sweep
+	MaxLeadTimes = 4
+	// MaxLeadTime is the earliest a reminder can go out before a sweep
+	MaxLeadTime = 7 * 24 * time.Hour
+)
+
+var (
+	// clockRegex matches a time of day such as "8PM", "7:30AM" or "20:00"
+	clockRegex = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(AM|PM)$|^(\d{1,2}):(\d{2})$`)
+	// leadDurationRegex matches a duration such as "12H", "90M" or "1H30M". A bare number is in hours.
+	leadDurationRegex = regexp.MustCompile(`^(?:(\d{1,3})\s*H)?\s*(?:(\d{1,4})\s*M)?$|^(\d{1,3})$`)
+)
+
+// LeadTime is when a reminder goes out before a sweep: a duration before it starts, such as 12 hours, or a time
+// of day on the day before, such as 8pm the night before
+type LeadTime struct {
+	// Before is the minutes before the sweep starts. It is only used when DayBefore isn't set.
+	Before int `bson:"before,omitempty" json:"before,omitempty"`
+	// DayBefore is set for reminders at a time of day on the day before the sweep, At minutes after midnight
+	DayBefore bool `bson:"dayBefore,omitempty" json:"dayBefore,omitempty"`
+	At        int  `bson:"at,omitempty" json:"at,omitempty"`
+}
+
+// RemindAt is when the reminder for a sweep starting at moveBy goes out. Times of day are in loc.
+func (l LeadTime) RemindAt(moveBy time.Time, loc *time.Location) time.Time {
+	if !l.DayBefore {
+		return moveBy.Add(-time.Duration(l.Before) * time.Minute)
+	}
+	day := moveBy.In(loc).AddDate(0, 0, -1)
+	return time.Date(day.Year(), day.Month(), day.Day(), l.At/60, l.At%60, 0, 0, loc)
+}
+
+// String writes the lead time the way users type it after REMIND, such as "12h", "1h30m" or "8pm". It is also the
+// key reminders are tracked by, so each lead time has exactly one.
+func (l LeadTime) String() string {
+
+	if l.DayBefore {
+		return formatClock(l.At) + meridiem(l.At)
+	}
+
+	hours, minutes := l.Before/60, l.Before%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+}
+
+// ParseLeadTimes reads a list of lead times separated by commas or spaces, such as "12h, 1h" or "8pm 1h".
+// Repeats are dropped. It reports false when any entry can't be read, is longer than MaxLeadTime, or there
+// are none or more than MaxLeadTimes.
+func ParseLeadTimes(text string) ([]LeadTime, bool) {
+
+	entries := strings.FieldsFunc(strings.ToUpper(text), func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+
+	leadTimes := make([]LeadTime, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		for _, field := range splitLeadTimes(strings.TrimSpace(entry)) {
+			leadTime, valid := parseLeadTime(field)
+			if !valid {
+				return nil, false
+			}
+			if seen[leadTime.String()] {
+				continue
+			}
+			seen[leadTime.String()] = true
+			leadTimes = append(leadTimes, leadTime)
+		}
+	}
+
+	if len(leadTimes) == 0 || len(leadTimes) > MaxLeadTimes {
+		return nil, false
+	}
+	return leadTimes, true
+}
+
+// FormatLeadTimes writes lead times back as a list ParseLeadTimes reads
+func FormatLeadTimes(leadTimes []LeadTime) string {
+	formatted := make([]string, len(leadTimes))
+	for i, leadTime := range leadTimes {
+		formatted[i] = leadTime.String()
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// splitLeadTimes splits an entry on spaces, joining a word that isn't a lead time on its own to the one before
+// it, so "8 PM" is read as 8pm
+func splitLeadTimes(entry string) []string {
+
+	words := strings.Fields(entry)
+	fields := make([]string, 0, len(words))
+	for _, word := range words {
+		if len(fields) > 0 {
+			_, alone := parseLeadTime(word)
+			_, joined := parseLeadTime(fields[len(fields)-1] + word)
+			if !alone && joined {
+				fields[len(fields)-1] += word
+				continue
+			}
+		}
+		fields = append(fields, word)
+	}
+	return fields
+}
+
+func parseLeadTime(text string) (LeadTime, bool) {
+
+	if clockRegex.MatchString(text) {
+		at, valid := parseClock(text)
+		return LeadTime{DayBefore: true, At: at}, valid
+	}
+
+	match := leadDurationRegex.FindStringSubmatch(text)
+	if match == nil || len(text) == 0 {
+		return LeadTime{}, false
+	}
+	hours, _ := strconv.Atoi(match[1] + match[3])
+	minutes, _ := strconv.Atoi(match[2])
+	before := hours*60 + minutes
+	if before <= 0 || time.Duration(before)*time.Minute > MaxLeadTime {
+		return LeadTime{}, false
+	}
+	return LeadTime{Before: before}, true
+}
+
+// parseClock reads a time of day matched by clockRegex as minutes after midnight
+func parseClock(text string) (int, bool) {
+
+	match := clockRegex.FindStringSubmatch(text)
+	if match == nil {
+		return 0, false
+	}
+
+	hour, _ := strconv.Atoi(match[1] + match[4])
+	minute, _ := strconv.Atoi(match[2] + match[5])
+	if len(match[3]) > 0 {
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		hour = hour % 12
+		if match[3] == "PM" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLeadTimes(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		text  string
+		want  string
+		valid bool
+	}{
+		{name: "hours", text: "12h", want: "12h", valid: true},
+		{name: "bare number is hours", text: "3", want: "3h", valid: true},
+		{name: "minutes", text: "90m", want: "1h30m", valid: true},
+		{name: "hours and minutes", text: "1h30m", want: "1h30m", valid: true},
+		{name: "time of day", text: "8pm", want: "8pm", valid: true},
+		{name: "time of day with minutes", text: "7:30am", want: "7:30am", valid: true},
+		{name: "24 hour clock", text: "20:00", want: "8pm", valid: true},
+		{name: "midnight and noon", text: "12am, 12pm", want: "12am, 12pm", valid: true},
+		{name: "lower case with spaces", text: " 8 pm , 1h ", want: "8pm, 1h", valid: true},
+		{name: "space separated", text: "8pm 1h", want: "8pm, 1h", valid: true},
+		{name: "semicolons", text: "24h;1h", want: "24h, 1h", valid: true},
+		{name: "repeats dropped", text: "1h, 60m, 1H", want: "1h", valid: true},
+		{name: "a week", text: "168h", want: "168h", valid: true},
+		{name: "four", text: "1h, 2h, 3h, 4h", want: "1h, 2h, 3h, 4h", valid: true},
+		{name: "more than four", text: "1h, 2h, 3h, 4h, 5h", valid: false},
+		{name: "longer than a week", text: "169h", valid: false},
+		{name: "zero", text: "0h", valid: false},
+		{name: "empty", text: "", valid: false},
+		{name: "only separators", text: " , ; ", valid: false},
+		{name: "hour out of range", text: "13pm", valid: false},
+		{name: "minute out of range", text: "8:60pm", valid: false},
+		{name: "24 hour clock out of range", text: "24:00", valid: false},
+		{name: "one bad entry", text: "1h, soon", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			leadTimes, valid := ParseLeadTimes(test.text)
+			if valid != test.valid {
+				t.Fatalf("ParseLeadTimes(%q) valid = %v, want %v", test.text, valid, test.valid)
+			}
+			if got := FormatLeadTimes(leadTimes); valid && got != test.want {
+				t.Errorf("ParseLeadTimes(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLeadTimeRemindAt(t *testing.T) {
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		leadTime LeadTime
+		moveBy   time.Time
+		want     time.Time
+	}{
+		{
+			name:     "duration",
+			leadTime: LeadTime{Before: 90},
+			moveBy:   time.Date(2026, 6, 10, 9, 0, 0, 0, loc),
+			want:     time.Date(2026, 6, 10, 7, 30, 0, 0, loc),
+		},
+		{
+			name:     "time of day is the day before",
+			leadTime: LeadTime{DayBefore: true, At: 20 * 60},
+			moveBy:   time.Date(2026, 6, 10, 9, 0, 0, 0, loc),
+			want:     time.Date(2026, 6, 9, 20, 0, 0, 0, loc),
+		},
+		{
+			name:     "time of day across the start of the month",
+			leadTime: LeadTime{DayBefore: true, At: 7*60 + 30},
+			moveBy:   time.Date(2026, 7, 1, 9, 0, 0, 0, loc),
+			want:     time.Date(2026, 6, 30, 7, 30, 0, 0, loc),
+		},
+		{
+			name:     "time of day keeps the wall clock across a DST change",
+			leadTime: LeadTime{DayBefore: true, At: 20 * 60},
+			moveBy:   time.Date(2026, 3, 9, 9, 0, 0, 0, loc),
+			want:     time.Date(2026, 3, 8, 20, 0, 0, 0, loc),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.leadTime.RemindAt(test.moveBy, loc); !got.Equal(test.want) {
+				t.Errorf("RemindAt(%v) = %v, want %v", test.moveBy, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// nearbyMeters is how far apart two shared locations can be and still be the same parking spot, allowing for GPS drift
+	nearbyMeters = 100
+
+	earthRadiusMeters = 6371000
+)
+
+// Location is where the user's car is parked, taken from a shared map link, coordinates or a location vCard
+type Location struct {
+	Latitude  float64 `bson:"latitude" json:"latitude"`
+	Longitude float64 `bson:"longitude" json:"longitude"`
+	Street    string  `bson:"street,omitempty" json:"street,omitempty"`
+}
+
+// Near reports whether two locations are the same parking spot: close together, or on the same named street when
+// either has no coordinates, since a street can run for kilometres
+func (l Location) Near(other Location) bool {
+	if !l.hasCoordinates() || !other.hasCoordinates() {
+		return len(l.Street) > 0 && strings.EqualFold(l.Street, other.Street)
+	}
+
+	// an equirectangular approximation is plenty at these distances
+	latitude := (l.Latitude + other.Latitude) / 2 * math.Pi / 180
+	x := (other.Longitude - l.Longitude) * math.Pi / 180 * math.Cos(latitude)
+	y := (other.Latitude - l.Latitude) * math.Pi / 180
+	return math.Hypot(x, y)*earthRadiusMeters <= nearbyMeters
+}
+
+func (l Location) hasCoordinates() bool {
+	return l.Latitude != 0 || l.Longitude != 0
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+type OcrCacheEntry struct {
+	ContentHash    string    `bson:"contentHash"`
+	PerceptualHash int64     `bson:"perceptualHash"`
+	HashBands      []int     `bson:"hashBands"`
+	Text           string    `bson:"text"`
+	CreatedAt      time.Time `bson:"createdAt"`
+}
+
+type OcrCacheStats struct {
+	Hits          int64 `json:"hits"`
+	NearDupHits   int64 `json:"nearDuplicateHits"`
+	Misses        int64 `json:"misses"`
+	LookupErrors  int64 `json:"lookupErrors"`
+	StoreFailures int64 `json:"storeFailures"`
+}
@@ -1,8 +1,44 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A parkn goes through these statuses for each sweep, then back to pending for the next one. Alerts saved before
+// statuses were added have none and count as pending.
+const (
+	// ParknStatusPending is waiting for its next reminder
+	ParknStatusPending = "pending"
+	// ParknStatusSending has a reminder claimed by an alert run that is sending it
+	ParknStatusSending = "sending"
+	// ParknStatusSent has had a reminder sent, and gets any later ones too
+	ParknStatusSent = "sent"
+	// ParknStatusAcknowledged was answered with MOVED, so no more reminders go out for the sweep
+	ParknStatusAcknowledged = "acknowledged"
+	// ParknStatusExpired reached its sweep without the user replying MOVED
+	ParknStatusExpired = "expired"
+)
 
 type Parkn struct {
-	PhoneNumber string    `bson:"phoneNumber"`
-	MoveByDate  time.Time `bson:"moveByDate"`
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	PhoneNumber string             `bson:"phoneNumber"`
+	Channel     string             `bson:"channel,omitempty"`
+	MoveByDate  time.Time          `bson:"moveByDate"`
+	Schedule    Schedule           `bson:"schedule"`
+	Location    *Location          `bson:"location,omitempty"`
+	// Nickname is what the user calls the car, such as "the van", so alerts for several cars can be told apart
+	Nickname string `bson:"nickname,omitempty"`
+	// Status is where the parkn is in the lifecycle for the sweep at MoveByDate, and Reminded are the lead times
+	// already reminded of for it. Both are reset when the parkn moves to its next sweep.
+	Status   string   `bson:"status,omitempty"`
+	Reminded []string `bson:"reminded,omitempty"`
+	// ClaimedAt is when a run last moved the parkn to sending, so a claim left by a run that died can be taken over
+	ClaimedAt time.Time `bson:"claimedAt,omitempty"`
+}
+
+// Finished reports whether nothing more is sent for the current sweep
+func (p Parkn) Finished() bool {
+	return p.Status == ParknStatusAcknowledged || p.Status == ParknStatusExpired
 }
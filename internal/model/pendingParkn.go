@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type PendingParkn struct {
+	PhoneNumber string    `bson:"phoneNumber"`
+	Channel     string    `bson:"channel,omitempty"`
+	Schedule    Schedule  `bson:"schedule"`
+	MoveByDate  time.Time `bson:"moveByDate"`
+	Location    *Location `bson:"location,omitempty"`
+	ArchiveID   string    `bson:"archiveId"`
+	ExpiresAt   time.Time `bson:"expiresAt"`
+}
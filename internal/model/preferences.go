@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+const (
+	DeliveryText  = "text"
+	DeliveryVoice = "voice"
+)
+
+// Preferences are the per user settings changed by keywords. Missing values fall back to the defaults.
+type Preferences struct {
+	PhoneNumber string    `bson:"phoneNumber" json:"phoneNumber"`
+	Delivery    string    `bson:"delivery,omitempty" json:"delivery,omitempty"`
+	Language    string    `bson:"language,omitempty" json:"language,omitempty"`
+	UpdatedAt   time.Time `bson:"updatedAt" json:"updatedAt"`
+	// LeadTimes are when reminders go out before each sweep, such as 8pm the night before and 1 hour before
+	LeadTimes []LeadTime `bson:"leadTimes,omitempty" json:"leadTimes,omitempty"`
+	// QuietHours are when alerts are held. Unset uses the defaults, and quiet hours that are Off turn them off.
+	QuietHours *QuietHours `bson:"quietHours,omitempty" json:"quietHours,omitempty"`
+}
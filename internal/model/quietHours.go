@@ -0,0 +1,89 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// QuietHoursMargin is how long before quiet hours start a held alert goes out when waiting for them to end would
+// be too late
+const QuietHoursMargin = 15 * time.Minute
+
+// quietHoursRegex splits quiet hours such as "10PM-7AM", "22:00 - 07:00" or "10PM TO 7AM" into their two times
+var quietHoursRegex = regexp.MustCompile(`^(.+?)\s*(?:-|–|\bTO\b|\bA\b)\s*(.+)$`)
+
+// QuietHours are the hours each day alerts are held, Start and End in minutes after midnight. They can run past
+// midnight, such as 10pm to 7am, and are off when Start and End are the same.
+type QuietHours struct {
+	Start int `bson:"start" json:"start"`
+	End   int `bson:"end" json:"end"`
+}
+
+// Off reports whether there are no quiet hours
+func (q QuietHours) Off() bool {
+	return q.Start == q.End
+}
+
+// Hold returns when an alert that would go out at should be sent, given the sweep starts at deadline. Outside quiet
+// hours that's at. Inside them it's held until they end, unless the sweep starts first, in which case it goes out
+// QuietHoursMargin before they started. Times of day are in loc.
+func (q QuietHours) Hold(at, deadline time.Time, loc *time.Location) time.Time {
+
+	start, end, quiet := q.window(at, loc)
+	if !quiet {
+		return at
+	}
+	if end.Before(deadline) {
+		return end
+	}
+	return start.Add(-QuietHoursMargin)
+}
+
+// String writes the quiet hours the way users type them after QUIET, such as "10pm-7am"
+func (q QuietHours) String() string {
+	return formatClock(q.Start) + meridiem(q.Start) + "-" + formatClock(q.End) + meridiem(q.End)
+}
+
+// ParseQuietHours reads quiet hours such as "10pm-7am" or "22:00-07:00". It reports false when either time can't
+// be read or they are the same.
+func ParseQuietHours(text string) (QuietHours, bool) {
+
+	match := quietHoursRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(text)))
+	if match == nil {
+		return QuietHours{}, false
+	}
+
+	start, startValid := parseClock(strings.ReplaceAll(match[1], " ", ""))
+	end, endValid := parseClock(strings.ReplaceAll(match[2], " ", ""))
+	if !startValid || !endValid || start == end {
+		return QuietHours{}, false
+	}
+	return QuietHours{Start: start, End: end}, true
+}
+
+// window returns the quiet period at falls in, and false when it isn't in one
+func (q QuietHours) window(at time.Time, loc *time.Location) (time.Time, time.Time, bool) {
+
+	if q.Off() {
+		return time.Time{}, time.Time{}, false
+	}
+
+	local := at.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	// quiet hours past midnight started the day before for times early in the morning
+	for _, day := range []time.Time{midnight, midnight.AddDate(0, 0, -1)} {
+		// built from the wall clock rather than added to midnight, so days when DST changes keep the same hours
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, q.Start, 0, 0, loc)
+		endDay := day.Day()
+		if q.End < q.Start {
+			endDay++
+		}
+		end := time.Date(day.Year(), day.Month(), endDay, 0, q.End, 0, 0, loc)
+		if !at.Before(start) && at.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		text  string
+		want  QuietHours
+		valid bool
+	}{
+		{name: "overnight", text: "10pm-7am", want: QuietHours{Start: 22 * 60, End: 7 * 60}, valid: true},
+		{name: "24 hour clock", text: "22:00-07:00", want: QuietHours{Start: 22 * 60, End: 7 * 60}, valid: true},
+		{name: "spaces around the dash", text: "22:00 - 07:00", want: QuietHours{Start: 22 * 60, End: 7 * 60}, valid: true},
+		{name: "en dash", text: "10pm–7am", want: QuietHours{Start: 22 * 60, End: 7 * 60}, valid: true},
+		{name: "to", text: "10 PM to 7 AM", want: QuietHours{Start: 22 * 60, End: 7 * 60}, valid: true},
+		{name: "spanish a", text: "22:00 a 07:00", want: QuietHours{Start: 22 * 60, End: 7 * 60}, valid: true},
+		{name: "minutes", text: "11:30pm-6:15am", want: QuietHours{Start: 23*60 + 30, End: 6*60 + 15}, valid: true},
+		{name: "same day", text: "1pm-3pm", want: QuietHours{Start: 13 * 60, End: 15 * 60}, valid: true},
+		{name: "same start and end", text: "7am-7am", valid: false},
+		{name: "one time", text: "10pm", valid: false},
+		{name: "bad time", text: "10pm-25:00", valid: false},
+		{name: "words", text: "nights", valid: false},
+		{name: "empty", text: "", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quiet, valid := ParseQuietHours(test.text)
+			if valid != test.valid {
+				t.Fatalf("ParseQuietHours(%q) valid = %v, want %v", test.text, valid, test.valid)
+			}
+			if valid && quiet != test.want {
+				t.Errorf("ParseQuietHours(%q) = %+v, want %+v", test.text, quiet, test.want)
+			}
+		})
+	}
+}
+
+func TestQuietHoursString(t *testing.T) {
+	quiet := QuietHours{Start: 23*60 + 30, End: 7 * 60}
+	if got := quiet.String(); got != "11:30pm-7am" {
+		t.Errorf("String() = %q, want %q", got, "11:30pm-7am")
+	}
+}
+
+func TestQuietHoursHold(t *testing.T) {
+
+	loc, err := time.LoadLocation("EST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 1, day, hour, minute, 0, 0, loc)
+	}
+	overnight := QuietHours{Start: 22 * 60, End: 7 * 60}
+
+	tests := []struct {
+		name     string
+		quiet    QuietHours
+		at       time.Time
+		deadline time.Time
+		want     time.Time
+	}{
+		{name: "before quiet hours", quiet: overnight, at: at(5, 21, 59), deadline: at(6, 9, 0), want: at(5, 21, 59)},
+		{name: "at the start is held", quiet: overnight, at: at(5, 22, 0), deadline: at(6, 9, 0), want: at(6, 7, 0)},
+		{name: "before midnight is held to the morning", quiet: overnight, at: at(5, 23, 0), deadline: at(6, 9, 0), want: at(6, 7, 0)},
+		{name: "after midnight is held to the same morning", quiet: overnight, at: at(6, 3, 0), deadline: at(6, 9, 0), want: at(6, 7, 0)},
+		{name: "at the end is sent", quiet: overnight, at: at(6, 7, 0), deadline: at(6, 9, 0), want: at(6, 7, 0)},
+		{name: "sweep before the end goes out before the start", quiet: overnight, at: at(5, 23, 0), deadline: at(6, 6, 0), want: at(5, 21, 45)},
+		{name: "sweep at the end goes out before the start", quiet: overnight, at: at(6, 3, 0), deadline: at(6, 7, 0), want: at(5, 21, 45)},
+		{name: "same day quiet hours", quiet: QuietHours{Start: 13 * 60, End: 15 * 60}, at: at(5, 14, 0), deadline: at(6, 9, 0), want: at(5, 15, 0)},
+		{name: "after same day quiet hours", quiet: QuietHours{Start: 13 * 60, End: 15 * 60}, at: at(5, 16, 0), deadline: at(6, 9, 0), want: at(5, 16, 0)},
+		{name: "off", quiet: QuietHours{}, at: at(5, 23, 0), deadline: at(6, 9, 0), want: at(5, 23, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.quiet.Hold(test.at, test.deadline, loc); !got.Equal(test.want) {
+				t.Errorf("Hold(%v, %v) = %v, want %v", test.at, test.deadline, got, test.want)
+			}
+		})
+	}
+}
+
+func TestQuietHoursHoldAcrossDST(t *testing.T) {
+
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, loc)
+	}
+	overnight := QuietHours{Start: 22 * 60, End: 7 * 60}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		deadline time.Time
+		want     time.Time
+	}{
+		{name: "clocks went forward that morning", at: at(time.March, 8, 22, 30), deadline: at(time.March, 9, 9, 0), want: at(time.March, 9, 7, 0)},
+		{name: "clocks go forward overnight", at: at(time.March, 7, 23, 0), deadline: at(time.March, 8, 9, 0), want: at(time.March, 8, 7, 0)},
+		{name: "before the start on the day clocks went back", at: at(time.November, 1, 21, 30), deadline: at(time.November, 2, 9, 0), want: at(time.November, 1, 21, 30)},
+		{name: "clocks go back overnight", at: at(time.October, 31, 23, 0), deadline: at(time.November, 1, 9, 0), want: at(time.November, 1, 7, 0)},
+		{name: "sweep first on the day clocks went forward", at: at(time.March, 8, 23, 0), deadline: at(time.March, 9, 6, 0), want: at(time.March, 8, 21, 45)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := overnight.Hold(test.at, test.deadline, loc); !got.Equal(test.want) {
+				t.Errorf("Hold(%v, %v) = %v, want %v", test.at, test.deadline, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// RateCounter counts events for a key within one fixed window. Counters expire once their window ends.
+type RateCounter struct {
+	Key         string    `bson:"key" json:"key"`
+	Count       int       `bson:"count" json:"count"`
+	WindowStart time.Time `bson:"windowStart" json:"windowStart"`
+	ExpiresAt   time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// BlockedSender is a phone number whose messages are ignored after it kept going over the rate limits.
+// A zero ExpiresAt blocks the number until an admin unblocks it.
+type BlockedSender struct {
+	PhoneNumber string    `bson:"phoneNumber" json:"phoneNumber"`
+	Reason      string    `bson:"reason" json:"reason"`
+	Strikes     int       `bson:"strikes" json:"strikes"`
+	BlockedAt   time.Time `bson:"blockedAt" json:"blockedAt"`
+	ExpiresAt   time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+}
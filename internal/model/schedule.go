@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	ordinalNames = map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 5: "5th"}
+	weekdayNames = map[int]string{1: "Monday", 2: "Tuesday", 3: "Wednesday", 4: "Thursday", 5: "Friday", 6: "Saturday", 7: "Sunday"}
+)
+
+// Schedule is a recurring street sweeping schedule such as "1st & 3rd Monday 8-10am". DayOfWeek runs
+// from 1 for Monday to 7 for Sunday and the window is in minutes after midnight.
+type Schedule struct {
+	DayOfWeek   int   `bson:"dayOfWeek" json:"dayOfWeek"`
+	Occurrences []int `bson:"occurrences" json:"occurrences"`
+	HasWindow   bool  `bson:"hasWindow" json:"hasWindow"`
+	WindowStart int   `bson:"windowStart" json:"windowStart"`
+	WindowEnd   int   `bson:"windowEnd" json:"windowEnd"`
+}
+
+func (s Schedule) String() string {
+
+	ordinals := make([]string, 0, len(s.Occurrences))
+	for _, occurrence := range s.Occurrences {
+		ordinals = append(ordinals, ordinalNames[occurrence])
+	}
+
+	str := fmt.Sprintf("%s %s", strings.Join(ordinals, " & "), weekdayNames[s.DayOfWeek])
+	if s.HasWindow {
+		str += " " + s.Window()
+	}
+	return str
+}
+
+// Window renders the sweeping window as "8–10am", or returns an empty string when the sign gave none
+func (s Schedule) Window() string {
+	if !s.HasWindow {
+		return ""
+	}
+	return formatWindow(s.WindowStart, s.WindowEnd)
+}
+
+// formatWindow renders a window as "8–10am" or "11am–1pm", only repeating the meridiem when it changes
+func formatWindow(start, end int) string {
+	startMeridiem, endMeridiem := meridiem(start), meridiem(end)
+	if startMeridiem == endMeridiem {
+		return fmt.Sprintf("%s–%s%s", formatClock(start), formatClock(end), endMeridiem)
+	}
+	return fmt.Sprintf("%s%s–%s%s", formatClock(start), startMeridiem, formatClock(end), endMeridiem)
+}
+
+func formatClock(minutes int) string {
+	hour := (minutes / 60) % 12
+	if hour == 0 {
+		hour = 12
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprint(hour)
+	}
+	return fmt.Sprintf("%d:%02d", hour, minutes%60)
+}
+
+func meridiem(minutes int) string {
+	if minutes < 12*60 {
+		return "am"
+	}
+	return "pm"
+}
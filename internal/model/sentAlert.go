@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AlertStatusSent         = "sent"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusSnoozed      = "snoozed"
+	AlertStatusRetrying     = "retrying"
+	// AlertStatusFailed is an alert that couldn't be delivered by text or call and was given up on
+	AlertStatusFailed = "failed"
+)
+
+// DeliveryEvent is a status Twilio reported for an alert text, such as "delivered" or "undelivered"
+type DeliveryEvent struct {
+	MessageSid string    `bson:"messageSid"`
+	Status     string    `bson:"status"`
+	ErrorCode  string    `bson:"errorCode,omitempty"`
+	ReportedAt time.Time `bson:"reportedAt"`
+}
+
+type SentAlert struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	PhoneNumber    string             `bson:"phoneNumber"`
+	Channel        string             `bson:"channel,omitempty"`
+	Delivery       string             `bson:"delivery,omitempty"`
+	Language       string             `bson:"language,omitempty"`
+	CallSid        string             `bson:"callSid,omitempty"`
+	CallAttempts   int                `bson:"callAttempts,omitempty"`
+	MessageSid     string             `bson:"messageSid,omitempty"`
+	TextAttempts   int                `bson:"textAttempts,omitempty"`
+	RetryAt        time.Time          `bson:"retryAt,omitempty"`
+	Body           string             `bson:"body"`
+	Status         string             `bson:"status"`
+	SentAt         time.Time          `bson:"sentAt"`
+	AcknowledgedAt time.Time          `bson:"acknowledgedAt,omitempty"`
+	SnoozedUntil   time.Time          `bson:"snoozedUntil,omitempty"`
+	SnoozeCount    int                `bson:"snoozeCount"`
+	// DeliveryStatus is the latest status Twilio reported for the alert text, and DeliveryEvents all of them in order
+	DeliveryStatus string          `bson:"deliveryStatus,omitempty"`
+	DeliveryEvents []DeliveryEvent `bson:"deliveryEvents,omitempty"`
+	// Parkn is the alert that was sent, kept so reminders can mention the street and car
+	Parkn *Parkn `bson:"parkn,omitempty"`
+}
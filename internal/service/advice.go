@@ -0,0 +1,19 @@
+package service
+
+import "github.com/willtowle1/parkn/internal/common/i18n"
+
+// Advice is an error the user can act on. Reason is logged while the message under Key is sent back to the user
+// in their language.
+type Advice struct {
+	Reason string
+	Key    i18n.Key
+}
+
+func NewAdvice(reason string, key i18n.Key) *Advice {
+	return &Advice{
+		Reason: reason,
+		Key:    key,
+	}
+}
+
+func (a *Advice) Error() string { return a.Reason }
@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	errRecordAlert      = "error while recording sent alert"
+	errAcknowledgeAlert = "error while acknowledging alert"
+	errSnoozeAlert      = "error while snoozing alert"
+	errClaimSnooze      = "error while claiming snoozed alert"
+	errResnooze         = "error while snoozing alert again"
+	errCallEnded        = "error while recording ended call"
+	errClaimRetry       = "error while claiming alert to retry"
+	errScheduleRetry    = "error while scheduling alert retry"
+	errRedelivery       = "error while recording alert redelivery"
+	errMessageStatus    = "error while recording message status"
+	errMarkFailed       = "error while marking alert failed"
+	errEarlyStatus      = "error while replaying message status reported before its alert was recorded"
+	errNoAlertSent      = "no alert has been sent to this number"
+
+	msgAlertAcknowledged = "alert acknowledged"
+	msgAlertSnoozed      = "alert snoozed"
+	msgCallUnanswered    = "alert call unanswered, retry scheduled"
+	msgTextUndelivered   = "alert text not delivered, retry scheduled"
+	msgUnknownMessage    = "status reported for a message that isn't an alert yet, kept for when it is"
+)
+
+var (
+	// call statuses Twilio reports for calls nobody picked up
+	unansweredCallStatuses = map[string]bool{
+		"no-answer": true,
+		"busy":      true,
+		"failed":    true,
+	}
+
+	// message statuses Twilio reports for texts the carrier never delivered
+	undeliveredMessageStatuses = map[string]bool{
+		"undelivered": true,
+		"failed":      true,
+	}
+
+	// alert statuses MOVED and SNOOZE apply to, so a reply never reopens an alert that was already handled
+	openAlertStatuses = []string{model.AlertStatusSent, model.AlertStatusSnoozed, model.AlertStatusRetrying}
+
+	// message statuses after which Twilio reports nothing more, so earlier ones arriving late are kept out of deliveryStatus
+	finalMessageStatuses = []string{"delivered", "undelivered", "failed", "read", "canceled"}
+)
+
+var (
+	ErrNoAlertSent = errors.New(errNoAlertSent)
+)
+
+type ISentAlertDal interface {
+	CreateOne(ctx context.Context, input model.SentAlert) (string, error)
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, sort interface{}) (model.SentAlert, bool, error)
+}
+
+type IDeliveryEventDal interface {
+	CreateOne(ctx context.Context, input model.DeliveryEvent) (string, error)
+	FindOneAndDelete(ctx context.Context, filter interface{}) (model.DeliveryEvent, bool, error)
+}
+
+// AlertLog keeps the alerts sent to each number so replies such as MOVED and SNOOZE can be tied to the latest one
+type AlertLog struct {
+	logger     logger.Logger
+	repository ISentAlertDal
+	// earlyStatuses keeps statuses Twilio reports before the alert's message SID was recorded, so none are lost
+	earlyStatuses  IDeliveryEventDal
+	defaultSnooze  time.Duration
+	maxSnooze      time.Duration
+	callRetryDelay time.Duration
+	textRetryDelay time.Duration
+}
+
+func NewAlertLog(logger logger.Logger, repository ISentAlertDal, earlyStatuses IDeliveryEventDal, defaultSnooze, maxSnooze, callRetryDelay, textRetryDelay time.Duration) *AlertLog {
+	return &AlertLog{
+		logger:         logger,
+		repository:     repository,
+		earlyStatuses:  earlyStatuses,
+		defaultSnooze:  defaultSnooze,
+		maxSnooze:      maxSnooze,
+		callRetryDelay: callRetryDelay,
+		textRetryDelay: textRetryDelay,
+	}
+}
+
+// Record stores an alert that was just sent. Body is the text sent, or the words spoken on a call.
+func (l *AlertLog) Record(ctx context.Context, alert model.SentAlert) error {
+
+	alert.Status = model.AlertStatusSent
+	alert.SentAt = time.Now()
+	if alert.Delivery == model.DeliveryVoice {
+		alert.CallAttempts = 1
+	} else {
+		alert.TextAttempts = 1
+	}
+
+	_, err := l.repository.CreateOne(ctx, alert)
+	if err != nil {
+		return errs.WrapError(errRecordAlert, err)
+	}
+	return l.replayEarlyStatuses(ctx, alert.MessageSid)
+}
+
+// Acknowledge marks the most recent open alert sent to the number as handled, cancelling any pending snooze, and returns it
+func (l *AlertLog) Acknowledge(ctx context.Context, phoneNumber string) (model.SentAlert, error) {
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.AlertStatusAcknowledged},
+			{Key: "acknowledgedAt", Value: time.Now()},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "snoozedUntil", Value: ""}}},
+	}
+
+	alert, found, err := l.repository.FindOneAndUpdate(ctx, l.latestFilter(phoneNumber), update, l.latestSort())
+	if err != nil {
+		return model.SentAlert{}, errs.WrapError(errAcknowledgeAlert, err)
+	}
+	if !found {
+		return model.SentAlert{}, ErrNoAlertSent
+	}
+
+	l.logger.Info(ctx, msgAlertAcknowledged, "phoneNumber", phoneNumber)
+	return alert, nil
+}
+
+// Snooze schedules the most recent open alert sent to the number to be sent again after the given duration. A zero
+// duration uses the default and longer ones are capped at the maximum.
+func (l *AlertLog) Snooze(ctx context.Context, phoneNumber string, duration time.Duration) (time.Time, error) {
+
+	if duration <= 0 {
+		duration = l.defaultSnooze
+	}
+	duration = min(duration, l.maxSnooze)
+
+	snoozedUntil := time.Now().Add(duration)
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.AlertStatusSnoozed},
+			{Key: "snoozedUntil", Value: snoozedUntil},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "snoozeCount", Value: 1}}},
+	}
+
+	_, found, err := l.repository.FindOneAndUpdate(ctx, l.latestFilter(phoneNumber), update, l.latestSort())
+	if err != nil {
+		return time.Time{}, errs.WrapError(errSnoozeAlert, err)
+	}
+	if !found {
+		return time.Time{}, ErrNoAlertSent
+	}
+
+	l.logger.Info(ctx, msgAlertSnoozed, "phoneNumber", phoneNumber, "snoozedUntil", snoozedUntil.Format(time.RFC3339))
+	return snoozedUntil, nil
+}
+
+// ClaimDueSnooze atomically takes one snoozed alert whose time has come and marks it sent again, so concurrent
+// runs never send the same follow-up twice. found is false when nothing is due.
+func (l *AlertLog) ClaimDueSnooze(ctx context.Context, now time.Time) (model.SentAlert, bool, error) {
+
+	filter := bson.D{
+		{Key: "status", Value: model.AlertStatusSnoozed},
+		{Key: "snoozedUntil", Value: bson.D{
+			{Key: "$lte", Value: primitive.NewDateTimeFromTime(now)},
+		}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.AlertStatusSent},
+			{Key: "sentAt", Value: now},
+			{Key: "callAttempts", Value: 0},
+			{Key: "textAttempts", Value: 0},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "snoozedUntil", Value: ""}}},
+	}
+
+	alert, found, err := l.repository.FindOneAndUpdate(ctx, filter, update, bson.D{{Key: "snoozedUntil", Value: 1}})
+	if err != nil {
+		return model.SentAlert{}, false, errs.WrapError(errClaimSnooze, err)
+	}
+	return alert, found, nil
+}
+
+// Resnooze puts a claimed snoozed alert back to be sent at until, used when its follow-up failed to send
+func (l *AlertLog) Resnooze(ctx context.Context, id primitive.ObjectID, until time.Time) error {
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: model.AlertStatusSent},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.AlertStatusSnoozed},
+		{Key: "snoozedUntil", Value: until},
+	}}}
+
+	_, _, err := l.repository.FindOneAndUpdate(ctx, filter, update, nil)
+	if err != nil {
+		return errs.WrapError(errResnooze, err)
+	}
+	return nil
+}
+
+// CallEnded schedules another attempt when Twilio reports an alert call went unanswered
+func (l *AlertLog) CallEnded(ctx context.Context, callSid, callStatus string) error {
+
+	if !unansweredCallStatuses[callStatus] {
+		return nil
+	}
+
+	filter := bson.D{
+		{Key: "callSid", Value: callSid},
+		{Key: "status", Value: model.AlertStatusSent},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.AlertStatusRetrying},
+		{Key: "retryAt", Value: time.Now().Add(l.callRetryDelay)},
+	}}}
+
+	_, found, err := l.repository.FindOneAndUpdate(ctx, filter, update, nil)
+	if err != nil {
+		return errs.WrapError(errCallEnded, err)
+	}
+	if found {
+		l.logger.Info(ctx, msgCallUnanswered, "callSid", callSid, "callStatus", callStatus)
+	}
+	return nil
+}
+
+// MessageStatus records a status Twilio reported for an alert text and schedules another attempt when the carrier
+// didn't deliver it. A status can arrive before the send returned and the alert's message SID was recorded, so
+// statuses for unknown messages are kept and replayed once it is.
+func (l *AlertLog) MessageStatus(ctx context.Context, messageSid, messageStatus, errorCode string) error {
+
+	event := model.DeliveryEvent{
+		MessageSid: messageSid,
+		Status:     messageStatus,
+		ErrorCode:  errorCode,
+		ReportedAt: time.Now(),
+	}
+
+	found, err := l.applyStatus(ctx, event)
+	if err != nil || found {
+		return err
+	}
+
+	l.logger.Debug(ctx, msgUnknownMessage, "messageSid", messageSid, "messageStatus", messageStatus)
+	_, err = l.earlyStatuses.CreateOne(ctx, event)
+	if err != nil {
+		return errs.WrapError(errMessageStatus, err)
+	}
+
+	// the alert may have been recorded since it was looked up, after its early statuses were replayed
+	return l.replayEarlyStatuses(ctx, messageSid)
+}
+
+// replayEarlyStatuses applies the statuses kept for a message SID before its alert was recorded. Each is taken
+// atomically so it is applied once, and put back if the alert still isn't there.
+func (l *AlertLog) replayEarlyStatuses(ctx context.Context, messageSid string) error {
+
+	if len(messageSid) == 0 {
+		return nil
+	}
+
+	for {
+		event, found, err := l.earlyStatuses.FindOneAndDelete(ctx, bson.D{{Key: "messageSid", Value: messageSid}})
+		if err != nil {
+			return errs.WrapError(errEarlyStatus, err)
+		}
+		if !found {
+			return nil
+		}
+
+		applied, err := l.applyStatus(ctx, event)
+		if err != nil {
+			return errs.WrapError(errEarlyStatus, err)
+		}
+		if !applied {
+			_, err = l.earlyStatuses.CreateOne(ctx, event)
+			if err != nil {
+				return errs.WrapError(errEarlyStatus, err)
+			}
+			return nil
+		}
+	}
+}
+
+// applyStatus records a delivery event on the alert with its message SID, reporting false when there is none
+func (l *AlertLog) applyStatus(ctx context.Context, event model.DeliveryEvent) (bool, error) {
+
+	messageSid, messageStatus, errorCode := event.MessageSid, event.Status, event.ErrorCode
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: "deliveryEvents", Value: event}}}}
+
+	_, found, err := l.repository.FindOneAndUpdate(ctx, bson.D{{Key: "messageSid", Value: messageSid}}, update, nil)
+	if err != nil {
+		return false, errs.WrapError(errMessageStatus, err)
+	}
+	if !found {
+		return false, nil
+	}
+
+	// callbacks can arrive out of order, so a late "sent" never replaces "delivered"
+	filter := bson.D{
+		{Key: "messageSid", Value: messageSid},
+		{Key: "deliveryStatus", Value: bson.D{{Key: "$nin", Value: finalMessageStatuses}}},
+	}
+	update = bson.D{{Key: "$set", Value: bson.D{{Key: "deliveryStatus", Value: messageStatus}}}}
+	_, _, err = l.repository.FindOneAndUpdate(ctx, filter, update, nil)
+	if err != nil {
+		return true, errs.WrapError(errMessageStatus, err)
+	}
+
+	if !undeliveredMessageStatuses[messageStatus] {
+		return true, nil
+	}
+
+	filter = bson.D{
+		{Key: "messageSid", Value: messageSid},
+		{Key: "status", Value: model.AlertStatusSent},
+	}
+	update = bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.AlertStatusRetrying},
+		{Key: "retryAt", Value: time.Now().Add(l.textRetryDelay)},
+	}}}
+
+	_, found, err = l.repository.FindOneAndUpdate(ctx, filter, update, nil)
+	if err != nil {
+		return true, errs.WrapError(errMessageStatus, err)
+	}
+	if found {
+		l.logger.Info(ctx, msgTextUndelivered, "messageSid", messageSid, "messageStatus", messageStatus, "errorCode", errorCode)
+	}
+	return true, nil
+}
+
+// ClaimDueRetry atomically takes one alert whose unanswered call or undelivered text is due to be retried. found is false when nothing is due.
+func (l *AlertLog) ClaimDueRetry(ctx context.Context, now time.Time) (model.SentAlert, bool, error) {
+
+	filter := bson.D{
+		{Key: "status", Value: model.AlertStatusRetrying},
+		{Key: "retryAt", Value: bson.D{
+			{Key: "$lte", Value: primitive.NewDateTimeFromTime(now)},
+		}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.AlertStatusSent},
+			{Key: "sentAt", Value: now},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "retryAt", Value: ""}}},
+	}
+
+	alert, found, err := l.repository.FindOneAndUpdate(ctx, filter, update, bson.D{{Key: "retryAt", Value: 1}})
+	if err != nil {
+		return model.SentAlert{}, false, errs.WrapError(errClaimRetry, err)
+	}
+	return alert, found, nil
+}
+
+// ScheduleRetry puts a claimed alert back to be retried at the given time after sending it by delivery failed,
+// counting the failed attempt so calls still fall back to a text and texts still escalate to a call
+func (l *AlertLog) ScheduleRetry(ctx context.Context, id primitive.ObjectID, delivery string, at time.Time) error {
+
+	attempts := "textAttempts"
+	if delivery == model.DeliveryVoice {
+		attempts = "callAttempts"
+	}
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: model.AlertStatusSent},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.AlertStatusRetrying},
+			{Key: "retryAt", Value: at},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: attempts, Value: 1}}},
+	}
+
+	_, _, err := l.repository.FindOneAndUpdate(ctx, filter, update, nil)
+	if err != nil {
+		return errs.WrapError(errScheduleRetry, err)
+	}
+	return nil
+}
+
+// HoldRetry puts a claimed alert back to be retried at the given time without counting an attempt, used when it came
+// due in the user's quiet hours
+func (l *AlertLog) HoldRetry(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: model.AlertStatusSent},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.AlertStatusRetrying},
+		{Key: "retryAt", Value: at},
+	}}}
+
+	_, _, err := l.repository.FindOneAndUpdate(ctx, filter, update, nil)
+	if err != nil {
+		return errs.WrapError(errScheduleRetry, err)
+	}
+	return nil
+}
+
+// RecordRedelivery notes how a snoozed or retried alert went out again from its delivery, SIDs and body, counting
+// the attempt against its delivery. The delivery status starts over for the new message.
+func (l *AlertLog) RecordRedelivery(ctx context.Context, alert model.SentAlert) error {
+
+	attempts := "textAttempts"
+	if alert.Delivery == model.DeliveryVoice {
+		attempts = "callAttempts"
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "delivery", Value: alert.Delivery},
+			{Key: "callSid", Value: alert.CallSid},
+			{Key: "messageSid", Value: alert.MessageSid},
+			{Key: "body", Value: alert.Body},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "deliveryStatus", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: attempts, Value: 1}}},
+	}
+
+	_, _, err := l.repository.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: alert.ID}}, update, nil)
+	if err != nil {
+		return errs.WrapError(errRedelivery, err)
+	}
+	return l.replayEarlyStatuses(ctx, alert.MessageSid)
+}
+
+// MarkFailed gives up on an alert that couldn't be delivered any way
+func (l *AlertLog) MarkFailed(ctx context.Context, id primitive.ObjectID) error {
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: model.AlertStatusFailed}}}}
+
+	_, _, err := l.repository.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: id}}, update, nil)
+	if err != nil {
+		return errs.WrapError(errMarkFailed, err)
+	}
+	return nil
+}
+
+func (l *AlertLog) latestFilter(phoneNumber string) bson.D {
+	return bson.D{
+		{Key: "phoneNumber", Value: phoneNumber},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: openAlertStatuses}}},
+	}
+}
+
+func (l *AlertLog) latestSort() bson.D {
+	return bson.D{{Key: "sentAt", Value: -1}}
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/willtowle1/parkn/internal/common/errs"
 	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -14,53 +16,227 @@ const (
 	errGetParknsToAlert = "error while getting parkns to alert"
 	errDeletingParkn    = "error while deleting parkn"
 	errNoParknDeleted   = "delete count of zero while deleting parkn"
+	errRollingForward   = "error while rolling parkn forward"
+	errClaimReminders   = "error while claiming parkn reminders"
+	errReleaseReminders = "error while releasing parkn reminders"
+	errFindParkn        = "error while finding parkn"
+	errExpiringParkn    = "error while expiring parkn"
+	errMarkingSent      = "error while marking parkn sent"
+
+	msgParknChanged = "parkn was changed or canceled while alerting, left as is"
+
+	// sendingClaimLease is how long a parkn stays sending for the run that claimed it, so one left by a run that died
+	// mid-send doesn't hold back the rest of the sweep's reminders
+	sendingClaimLease = 5 * time.Minute
 )
 
+// finishedStatuses are the ends of a parkn's lifecycle for one sweep, after which nothing more is sent for it
+var finishedStatuses = []string{model.ParknStatusAcknowledged, model.ParknStatusExpired}
+
+type IOccurrenceFinder interface {
+	NextOccurrence(schedule model.Schedule, after time.Time) (time.Time, error)
+}
+
 type AlertService struct {
-	logger     logger.Logger
-	repository IDal
+	logger      logger.Logger
+	repository  IDal
+	occurrences IOccurrenceFinder
 }
 
-func NewAlertService(logger logger.Logger, repository IDal) *AlertService {
+func NewAlertService(logger logger.Logger, repository IDal, occurrences IOccurrenceFinder) *AlertService {
 	return &AlertService{
-		logger:     logger,
-		repository: repository,
+		logger:      logger,
+		repository:  repository,
+		occurrences: occurrences,
 	}
 }
 
-func (s *AlertService) GetParknsToAlert(ctx context.Context, tomorrow time.Time) ([]string, error) {
+// GetParknsToAlert returns every parkn whose sweep is before horizon, so any of its reminders could be due
+func (s *AlertService) GetParknsToAlert(ctx context.Context, horizon time.Time) ([]model.Parkn, error) {
 
 	filter := bson.D{
 		{Key: "moveByDate", Value: bson.D{
-			{Key: "$lte", Value: primitive.NewDateTimeFromTime(tomorrow)},
+			{Key: "$lte", Value: primitive.NewDateTimeFromTime(horizon)},
 		}},
 	}
 
-	phoneNumbers := make([]string, 0)
 	parkns, err := s.repository.Get(ctx, filter)
 	if err != nil {
-		return phoneNumbers, errs.WrapError(errGetParknsToAlert, err)
+		return nil, errs.WrapError(errGetParknsToAlert, err)
+	}
+
+	return parkns, nil
+}
+
+// RollForward moves a parkn whose sweep is over to the next sweep on its schedule, back in pending, so the user keeps
+// getting alerts without sending the sign again. Sweeps missed while alerts weren't running are skipped.
+func (s *AlertService) RollForward(ctx context.Context, parkn model.Parkn) (time.Time, error) {
+
+	after := parkn.MoveByDate
+	if now := time.Now(); after.Before(now) {
+		after = now
+	}
+
+	next, err := s.occurrences.NextOccurrence(parkn.Schedule, after)
+	if err != nil {
+		return time.Time{}, errs.WrapError(errRollingForward, err)
+	}
+
+	// only the finished sweep is moved, so a parkn corrected in the meantime keeps its new date
+	filter := bson.D{
+		{Key: "_id", Value: parkn.ID},
+		{Key: "moveByDate", Value: parkn.MoveByDate},
+		{Key: "status", Value: bson.D{{Key: "$in", Value: finishedStatuses}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "moveByDate", Value: next},
+			{Key: "status", Value: model.ParknStatusPending},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "reminded", Value: ""}}},
+	}
+
+	err = s.transition(ctx, parkn, filter, update, errRollingForward)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return next, nil
+}
+
+// Expire ends the sweep for a parkn the user never answered with MOVED, once the sweep has started
+func (s *AlertService) Expire(ctx context.Context, parkn model.Parkn) error {
+
+	filter := bson.D{
+		{Key: "_id", Value: parkn.ID},
+		{Key: "moveByDate", Value: parkn.MoveByDate},
+		{Key: "status", Value: bson.D{{Key: "$nin", Value: finishedStatuses}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: model.ParknStatusExpired}}}}
+
+	return s.transition(ctx, parkn, filter, update, errExpiringParkn)
+}
+
+// ClaimReminders moves a parkn to sending and marks the lead times as reminded of for its current sweep, reporting
+// false when any of them already was, another run is sending, or the parkn has since changed. Only the run that
+// claims a reminder sends it, so none goes out twice. A sending claim older than sendingClaimLease was left by a run
+// that died and is taken over; the reminder it was sending is not sent again.
+func (s *AlertService) ClaimReminders(ctx context.Context, parkn model.Parkn, leadTimes []string) (bool, error) {
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "_id", Value: parkn.ID},
+		{Key: "moveByDate", Value: parkn.MoveByDate},
+		{Key: "status", Value: bson.D{{Key: "$nin", Value: finishedStatuses}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "status", Value: bson.D{{Key: "$ne", Value: model.ParknStatusSending}}}},
+			bson.D{{Key: "claimedAt", Value: bson.D{{Key: "$lt", Value: now.Add(-sendingClaimLease)}}}},
+			bson.D{{Key: "claimedAt", Value: bson.D{{Key: "$exists", Value: false}}}},
+		}},
+		{Key: "reminded", Value: bson.D{{Key: "$nin", Value: leadTimes}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.ParknStatusSending},
+			{Key: "claimedAt", Value: now},
+		}},
+		{Key: "$addToSet", Value: bson.D{
+			{Key: "reminded", Value: bson.D{{Key: "$each", Value: leadTimes}}},
+		}},
+	}
+
+	modified, err := s.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, errs.WrapError(errClaimReminders, err)
 	}
+	return modified > 0, nil
+}
+
+// MarkSent moves a parkn to sent once a reminder for its current sweep went out, whether sent by the claiming run
+// or later from the retry queue
+func (s *AlertService) MarkSent(ctx context.Context, parkn model.Parkn) error {
 
-	for _, parkn := range parkns {
-		phoneNumbers = append(phoneNumbers, parkn.PhoneNumber)
+	filter := bson.D{
+		{Key: "_id", Value: parkn.ID},
+		{Key: "moveByDate", Value: parkn.MoveByDate},
+		{Key: "status", Value: bson.D{{Key: "$nin", Value: finishedStatuses}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: model.ParknStatusSent}}}}
+
+	return s.transition(ctx, parkn, filter, update, errMarkingSent)
+}
+
+// SendFailed puts a parkn that was claimed by ClaimReminders back in the status it had before, releasing the given
+// lead times so the next run tries them again. Lead times the retry queue took stay claimed.
+func (s *AlertService) SendFailed(ctx context.Context, parkn model.Parkn, release []string) error {
+
+	previous := parkn.Status
+	if len(previous) == 0 {
+		previous = model.ParknStatusPending
+	}
+	// taken over from a run that died after claiming an earlier reminder, which may have gone out
+	if previous == model.ParknStatusSending {
+		previous = model.ParknStatusSent
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: parkn.ID},
+		{Key: "moveByDate", Value: parkn.MoveByDate},
+		{Key: "status", Value: model.ParknStatusSending},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: previous}}}}
+	if len(release) > 0 {
+		update = append(update, bson.E{Key: "$pullAll", Value: bson.D{{Key: "reminded", Value: release}}})
 	}
 
-	return phoneNumbers, nil
+	return s.transition(ctx, parkn, filter, update, errReleaseReminders)
 }
 
-func (s *AlertService) DeleteParkn(ctx context.Context, phoneNumber string) error {
+// AwaitingReminders reports whether a parkn is still saved and getting reminders for the given sweep, so retries for
+// one canceled, moved on or answered with MOVED in the meantime can be dropped
+func (s *AlertService) AwaitingReminders(ctx context.Context, parkn model.Parkn) (bool, error) {
+
 	filter := bson.D{
-		{Key: "phoneNumber", Value: phoneNumber},
+		{Key: "_id", Value: parkn.ID},
+		{Key: "moveByDate", Value: parkn.MoveByDate},
+		{Key: "status", Value: bson.D{{Key: "$nin", Value: finishedStatuses}}},
 	}
 
-	deleteCount, err := s.repository.DeleteOne(ctx, filter)
+	parkns, err := s.repository.Get(ctx, filter)
 	if err != nil {
-		return errs.WrapError(errDeleteParkn, err)
+		return false, errs.WrapError(errFindParkn, err)
+	}
+	return len(parkns) > 0, nil
+}
+
+// DeleteParkn deletes one parkn by its id
+func (s *AlertService) DeleteParkn(ctx context.Context, id primitive.ObjectID) error {
+
+	deleteCount, err := s.repository.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return errs.WrapError(errDeletingParkn, err)
 	}
 	if deleteCount == 0 {
-		return errs.WrapError(errNoParknDeleted, err)
+		return errors.New(errNoParknDeleted)
 	}
 
 	return nil
 }
+
+// claimHeld reports whether a parkn is sending for a run whose claim hasn't run out, so other runs leave it alone
+func claimHeld(parkn model.Parkn, now time.Time) bool {
+	return parkn.Status == model.ParknStatusSending && now.Before(parkn.ClaimedAt.Add(sendingClaimLease))
+}
+
+// transition applies a status change to one parkn. The filter holds the statuses it can move from, so a parkn
+// changed by another run or by the user in the meantime is left alone and logged.
+func (s *AlertService) transition(ctx context.Context, parkn model.Parkn, filter, update bson.D, errMsg string) error {
+
+	modified, err := s.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errMsg, err)
+	}
+	if modified == 0 {
+		s.logger.Info(ctx, msgParknChanged, "id", parkn.ID.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	errArchiveSubmission = "error while archiving submission"
+	errGetArchive        = "error while getting archive"
+	errArchiveNotFound   = "no archive found for parkn"
+	errPurgeArchives     = "error while purging expired archives"
+	errLinkArchive       = "error while linking archive to parkn"
+
+	msgArchiveSuccess = "successfully archived submission"
+	msgPurgeComplete  = "archive purge complete"
+)
+
+var ErrArchiveNotFound = errors.New(errArchiveNotFound)
+
+type IBlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type IArchiveDal interface {
+	CreateOne(ctx context.Context, input model.SubmissionArchive) (string, error)
+	Get(ctx context.Context, filter interface{}) ([]model.SubmissionArchive, error)
+	DeleteMany(ctx context.Context, filter interface{}) (int64, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}) (int64, error)
+}
+
+type Archiver struct {
+	logger     logger.Logger
+	blobStore  IBlobStore
+	repository IArchiveDal
+	retention  time.Duration
+}
+
+func NewArchiver(logger logger.Logger, blobStore IBlobStore, repository IArchiveDal, retention time.Duration) *Archiver {
+	return &Archiver{
+		logger:     logger,
+		blobStore:  blobStore,
+		repository: repository,
+		retention:  retention,
+	}
+}
+
+// Archive stores the original media in the blob store and the submission metadata alongside it.
+// media is matched to submission.Media by position. It returns the archive id.
+func (a *Archiver) Archive(ctx context.Context, submission model.SubmissionArchive, media [][]byte) (string, error) {
+
+	submission.CreatedAt = time.Now()
+
+	for i := range submission.Media {
+		if i >= len(media) || len(media[i]) == 0 {
+			continue
+		}
+		submission.Media[i].MediaKey = primitive.NewObjectID().Hex()
+		err := a.blobStore.Put(ctx, submission.Media[i].MediaKey, media[i])
+		if err != nil {
+			return "", errs.WrapError(errArchiveSubmission, err)
+		}
+	}
+
+	id, err := a.repository.CreateOne(ctx, submission)
+	if err != nil {
+		return "", errs.WrapError(errArchiveSubmission, err)
+	}
+
+	a.logger.Debug(ctx, msgArchiveSuccess, "id", id, "parknId", submission.ParknID)
+	return id, nil
+}
+
+// LinkParkn records the parkn created from an archived submission once it has been confirmed
+func (a *Archiver) LinkParkn(ctx context.Context, archiveID, parknID string) error {
+
+	id, err := primitive.ObjectIDFromHex(archiveID)
+	if err != nil {
+		return errs.WrapError(errLinkArchive, err)
+	}
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "parknId", Value: parknID}}}}
+
+	_, err = a.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errLinkArchive, err)
+	}
+
+	return nil
+}
+
+// GetByParknID returns the archived submission for a parkn
+func (a *Archiver) GetByParknID(ctx context.Context, parknID string) (model.SubmissionArchive, error) {
+
+	archives, err := a.repository.Get(ctx, bson.D{{Key: "parknId", Value: parknID}})
+	if err != nil {
+		return model.SubmissionArchive{}, errs.WrapError(errGetArchive, err)
+	}
+	if len(archives) == 0 {
+		return model.SubmissionArchive{}, ErrArchiveNotFound
+	}
+
+	return archives[0], nil
+}
+
+// GetMedia returns the original bytes and content type of the attachment at index for a parkn
+func (a *Archiver) GetMedia(ctx context.Context, parknID string, index int) ([]byte, string, error) {
+
+	archive, err := a.GetByParknID(ctx, parknID)
+	if err != nil {
+		return nil, "", err
+	}
+	if index < 0 || index >= len(archive.Media) || len(archive.Media[index].MediaKey) == 0 {
+		return nil, "", ErrArchiveNotFound
+	}
+
+	media, err := a.blobStore.Get(ctx, archive.Media[index].MediaKey)
+	if err != nil {
+		return nil, "", errs.WrapError(errGetArchive, err)
+	}
+
+	return media, archive.Media[index].ContentType, nil
+}
+
+// Purge removes archives and their media once they are older than the retention period
+func (a *Archiver) Purge(ctx context.Context) {
+
+	cutoff := time.Now().Add(-a.retention)
+	filter := bson.D{
+		{Key: "createdAt", Value: bson.D{
+			{Key: "$lt", Value: primitive.NewDateTimeFromTime(cutoff)},
+		}},
+	}
+
+	expired, err := a.repository.Get(ctx, filter)
+	if err != nil {
+		a.logger.Error(ctx, errPurgeArchives, err)
+		return
+	}
+
+	// an archive is only deleted once all of its media is, so a blob that fails to delete is retried next time
+	// rather than being left behind with nothing pointing at it
+	purged := make([]primitive.ObjectID, 0, len(expired))
+	for _, archive := range expired {
+		if a.deleteMedia(ctx, archive) {
+			purged = append(purged, archive.ID)
+		}
+	}
+	if len(purged) == 0 {
+		return
+	}
+
+	deleted, err := a.repository.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: purged}}}})
+	if err != nil {
+		a.logger.Error(ctx, errPurgeArchives, err)
+		return
+	}
+
+	a.logger.Info(ctx, msgPurgeComplete, "deleted", strconv.FormatInt(deleted, 10))
+}
+
+// deleteMedia removes an archive's blobs and reports whether they are all gone
+func (a *Archiver) deleteMedia(ctx context.Context, archive model.SubmissionArchive) bool {
+	deleted := true
+	for _, media := range archive.Media {
+		if len(media.MediaKey) == 0 {
+			continue
+		}
+		err := a.blobStore.Delete(ctx, media.MediaKey)
+		if err != nil {
+			a.logger.Error(ctx, errPurgeArchives, err, "parknId", archive.ParknID, "mediaKey", media.MediaKey)
+			deleted = false
+		}
+	}
+	return deleted
+}
@@ -2,52 +2,138 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/twilio/twilio-go"
-	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+	"github.com/willtowle1/parkn/internal/common/i18n"
 	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
 	errGettingParkns = "error while getting parkns to alert"
 	errFailedToAlert = "error while alerting"
 	errDeleteParkn   = "error while trying to delete parkn"
+	errRollForward   = "error while moving parkn to its next sweep"
+	errRecordingSent = "error while recording sent alert"
+	errSnoozedAlert  = "error while sending snoozed alert"
+	errRetryDelivery = "error while retrying alert delivery"
+	errUndeliverable = "alert could not be delivered by text or call"
+	errGaveUp        = "giving up on alert"
+	errPreferences   = "error while getting alert preferences"
+	errRetryFailed   = "error while retrying failed alert"
+	errSweepStarted  = "sweep started before the alert could be sent"
+	errHoldAlert     = "error while holding alert for quiet hours"
 
-	msgAlertSuccessful  = "successfully sent alert"
-	msgDeleteSuccessful = "successfully deleted parkn"
-	msgAlertComplete    = "alert logic complete"
+	msgAlertSuccessful   = "successfully sent alert"
+	msgRolledForward     = "moved parkn to its next sweep"
+	msgAlertComplete     = "alert logic complete"
+	msgSnoozedResent     = "successfully resent snoozed alert"
+	msgCallRetried       = "retried unanswered alert call"
+	msgCallFallback      = "alert call never answered, sent text instead"
+	msgSuppressedDropped = "recipient opted out, dropped alert"
+	msgTextRetried       = "resent undelivered alert text"
+	msgTextEscalated     = "alert text never delivered, called instead"
+	msgRetrySent         = "successfully sent queued alert"
+	msgRetryDropped      = "alert canceled or answered while queued, dropped retry"
+	msgExpired           = "sweep started without the alert being answered"
+	msgQuietHeld         = "alert came due in quiet hours, held"
 
-	alertMsg = "Move your car by tomorrow!"
+	// redeliveryRetryDelay is how long a snoozed or retried alert waits after failing to send before it is tried again
+	redeliveryRetryDelay = 5 * time.Minute
 )
 
 type IAlertService interface {
-	GetParknsToAlert(ctx context.Context, tomorrow time.Time) ([]string, error)
-	DeleteParkn(ctx context.Context, phoneNumber string) error
+	GetParknsToAlert(ctx context.Context, horizon time.Time) ([]model.Parkn, error)
+	DeleteParkn(ctx context.Context, id primitive.ObjectID) error
+	RollForward(ctx context.Context, parkn model.Parkn) (time.Time, error)
+	Expire(ctx context.Context, parkn model.Parkn) error
+	ClaimReminders(ctx context.Context, parkn model.Parkn, leadTimes []string) (bool, error)
+	MarkSent(ctx context.Context, parkn model.Parkn) error
+	SendFailed(ctx context.Context, parkn model.Parkn, release []string) error
+	AwaitingReminders(ctx context.Context, parkn model.Parkn) (bool, error)
+}
+
+type IRetryQueue interface {
+	Enqueue(ctx context.Context, parkn model.Parkn, leadTimes []string, cause error) error
+	ClaimDue(ctx context.Context, now time.Time) (model.AlertRetry, bool, error)
+	Remove(ctx context.Context, id primitive.ObjectID) error
+	Failed(ctx context.Context, retry model.AlertRetry, cause error) (bool, error)
+	Hold(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	DeadLetter(ctx context.Context, retry model.AlertRetry, cause error) error
+}
+
+type IMessenger interface {
+	SendTemplate(ctx context.Context, phoneNumber, channel, body string, template Template) (string, error)
+}
+
+type ICaller interface {
+	Call(ctx context.Context, phoneNumber, language, message string) (string, error)
+}
+
+type IAlertPreferences interface {
+	Get(ctx context.Context, phoneNumber string) (model.Preferences, error)
+	Defaults(phoneNumber string) model.Preferences
+}
+
+type IAlertLog interface {
+	Record(ctx context.Context, alert model.SentAlert) error
+	ClaimDueSnooze(ctx context.Context, now time.Time) (model.SentAlert, bool, error)
+	ClaimDueRetry(ctx context.Context, now time.Time) (model.SentAlert, bool, error)
+	Resnooze(ctx context.Context, id primitive.ObjectID, until time.Time) error
+	ScheduleRetry(ctx context.Context, id primitive.ObjectID, delivery string, at time.Time) error
+	HoldRetry(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	RecordRedelivery(ctx context.Context, alert model.SentAlert) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID) error
+}
+
+// AlertTemplates are the content SIDs of the approved WhatsApp templates used for alerts, since WhatsApp
+// only delivers free-form text within 24 hours of the user's last message
+type AlertTemplates struct {
+	Alert    string
+	Reminder string
 }
 
 type AutoAlertService struct {
-	logger       logger.Logger
-	service      IAlertService
-	twilio       *twilio.RestClient
-	twilioNumber string
+	logger          logger.Logger
+	service         IAlertService
+	messenger       IMessenger
+	alertLog        IAlertLog
+	templates       AlertTemplates
+	caller          ICaller
+	preferences     IAlertPreferences
+	retryQueue      IRetryQueue
+	maxCallAttempts int
+	maxTextAttempts int
 }
 
-func NewAutoAlertService(logger logger.Logger, service IAlertService, twilio *twilio.RestClient, twilioNumber string) *AutoAlertService {
+func NewAutoAlertService(logger logger.Logger, service IAlertService, messenger IMessenger, alertLog IAlertLog, templates AlertTemplates, caller ICaller, preferences IAlertPreferences, retryQueue IRetryQueue, maxCallAttempts, maxTextAttempts int) *AutoAlertService {
 	return &AutoAlertService{
-		logger:       logger,
-		service:      service,
-		twilio:       twilio,
-		twilioNumber: twilioNumber,
+		logger:          logger,
+		service:         service,
+		messenger:       messenger,
+		alertLog:        alertLog,
+		templates:       templates,
+		caller:          caller,
+		preferences:     preferences,
+		retryQueue:      retryQueue,
+		maxCallAttempts: maxCallAttempts,
+		maxTextAttempts: maxTextAttempts,
 	}
 }
 
 func (s *AutoAlertService) Alert(ctx context.Context) {
+	s.resendSnoozed(ctx)
+	s.retryDeliveries(ctx)
+	s.retryFailed(ctx)
+
 	loc, _ := time.LoadLocation("EST")
-	tomorrow := time.Now().In(loc).Add(24 * time.Hour)
+	now := time.Now().In(loc)
 
-	toAlert, err := s.service.GetParknsToAlert(ctx, tomorrow)
+	toAlert, err := s.service.GetParknsToAlert(ctx, now.Add(model.MaxLeadTime))
 	if err != nil {
 		s.logger.Error(ctx, errGettingParkns, err)
 		return
@@ -55,41 +141,516 @@ func (s *AutoAlertService) Alert(ctx context.Context) {
 
 	successful := make([]string, 0)
 	unsuccessful := make([]string, 0)
-	for _, phoneNumber := range toAlert {
-		err = s.sendAlert(phoneNumber)
+	for _, parkn := range toAlert {
+		phoneNumber := parkn.PhoneNumber
+
+		// once the sweep starts the parkn is done with it and moves to the next one, so alerts repeat until the
+		// user cancels them
+		if !now.Before(parkn.MoveByDate) {
+			err = s.finishSweep(ctx, parkn)
+			if err != nil {
+				unsuccessful = append(unsuccessful, phoneNumber)
+				s.logger.Error(ctx, errRollForward, err, "phoneNumber", phoneNumber, "id", parkn.ID.Hex())
+			}
+			continue
+		}
+		if parkn.Finished() || claimHeld(parkn, now) {
+			continue
+		}
+
+		preferences := s.userPreferences(ctx, phoneNumber)
+		due := dueReminders(parkn, preferences, now, loc)
+		if len(due) == 0 {
+			continue
+		}
+
+		sent, err := s.remind(ctx, parkn, preferences, due)
+		if errors.Is(err, ErrRecipientSuppressed) {
+			// STOP cancels alerts, so this only catches ones saved around the same time. They are never wanted.
+			s.logger.Info(ctx, msgSuppressedDropped, "phoneNumber", phoneNumber)
+			err = s.service.DeleteParkn(ctx, parkn.ID)
+			if err != nil {
+				s.logger.Error(ctx, errDeleteParkn, err, "phoneNumber", phoneNumber, "id", parkn.ID.Hex())
+			}
+			continue
+		}
 		if err != nil {
-			// TODO: would be better to place into a separate queue to process later
 			unsuccessful = append(unsuccessful, phoneNumber)
-			s.logger.Error(ctx, errFailedToAlert, err, "phoneNumber", phoneNumber)
+			s.logger.Error(ctx, errFailedToAlert, err, "phoneNumber", phoneNumber, "id", parkn.ID.Hex())
+			continue
+		}
+		if sent {
+			successful = append(successful, phoneNumber)
+		}
+	}
+
+	s.logger.Info(ctx, msgAlertComplete, "successful", strings.Join(successful, ", "), "unsuccessful", strings.Join(unsuccessful, ", "))
+}
+
+// userPreferences returns the user's preferences, or the defaults when they can't be read, so a preferences outage
+// doesn't hold up alerts
+func (s *AutoAlertService) userPreferences(ctx context.Context, phoneNumber string) model.Preferences {
+	preferences, err := s.preferences.Get(ctx, phoneNumber)
+	if err != nil {
+		s.logger.Error(ctx, errPreferences, err, "phoneNumber", phoneNumber)
+		return s.preferences.Defaults(phoneNumber)
+	}
+	return preferences
+}
+
+// finishSweep expires a parkn whose sweep started without the user replying MOVED, then moves it to its next sweep
+func (s *AutoAlertService) finishSweep(ctx context.Context, parkn model.Parkn) error {
+
+	if !parkn.Finished() {
+		err := s.service.Expire(ctx, parkn)
+		if err != nil {
+			return err
+		}
+		s.logger.Info(ctx, msgExpired, "phoneNumber", parkn.PhoneNumber, "id", parkn.ID.Hex())
+		parkn.Status = model.ParknStatusExpired
+	}
+
+	next, err := s.service.RollForward(ctx, parkn)
+	if err != nil {
+		return err
+	}
+	s.logger.Info(ctx, msgRolledForward, "phoneNumber", parkn.PhoneNumber, "id", parkn.ID.Hex(), "nextAlert", next.Format(time.DateOnly))
+	return nil
+}
+
+// dueReminders returns the lead times for the parkn's sweep that have come due and haven't been reminded of. Ones
+// that would go out in the user's quiet hours are held until they end, or sent just before they start when the
+// sweep begins first.
+func dueReminders(parkn model.Parkn, preferences model.Preferences, now time.Time, loc *time.Location) []string {
+
+	reminded := make(map[string]bool, len(parkn.Reminded))
+	for _, leadTime := range parkn.Reminded {
+		reminded[leadTime] = true
+	}
+
+	var quiet model.QuietHours
+	if preferences.QuietHours != nil {
+		quiet = *preferences.QuietHours
+	}
+
+	due := make([]string, 0, len(preferences.LeadTimes))
+	for _, leadTime := range preferences.LeadTimes {
+		if reminded[leadTime.String()] {
+			continue
+		}
+		// a reminder already past due goes out now, which may itself be in quiet hours
+		at := leadTime.RemindAt(parkn.MoveByDate, loc)
+		if at.Before(now) {
+			at = now
+		}
+		if !quiet.Hold(at, parkn.MoveByDate, loc).After(now) {
+			due = append(due, leadTime.String())
+		}
+	}
+	return due
+}
+
+// remind claims the due reminders, moving the parkn to sending, and sends a single alert for them, since several come
+// due together only when the parkn was saved or the lead times changed shortly before the sweep. It reports false
+// when another run claimed them.
+func (s *AutoAlertService) remind(ctx context.Context, parkn model.Parkn, preferences model.Preferences, due []string) (bool, error) {
+
+	claimed, err := s.service.ClaimReminders(ctx, parkn, due)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	sent, err := s.sendAlert(ctx, parkn, preferences)
+	if errors.Is(err, ErrRecipientSuppressed) {
+		return false, err
+	}
+	if err != nil {
+		// the reminders stay claimed while the retry queue has them. Only when it can't take them are they
+		// released for the next run to try again.
+		var release []string
+		if queueErr := s.retryQueue.Enqueue(ctx, parkn, due, err); queueErr != nil {
+			s.logger.Error(ctx, errEnqueueRetry, queueErr, "phoneNumber", parkn.PhoneNumber)
+			release = due
+		}
+		if failErr := s.service.SendFailed(ctx, parkn, release); failErr != nil {
+			s.logger.Error(ctx, errReleaseReminders, failErr, "phoneNumber", parkn.PhoneNumber, "id", parkn.ID.Hex())
+		}
+		return false, err
+	}
+
+	s.logger.Info(ctx, msgAlertSuccessful, "phoneNumber", parkn.PhoneNumber, "id", parkn.ID.Hex(), "leadTimes", strings.Join(due, ", "))
+	err = s.service.MarkSent(ctx, parkn)
+	if err != nil {
+		s.logger.Error(ctx, errMarkingSent, err, "phoneNumber", parkn.PhoneNumber, "id", parkn.ID.Hex())
+	}
+	err = s.alertLog.Record(ctx, sent)
+	if err != nil {
+		s.logger.Error(ctx, errRecordingSent, err, "phoneNumber", parkn.PhoneNumber)
+	}
+	return true, nil
+}
+
+// sendAlert calls users who asked for voice alerts and texts everyone else, returning what was sent
+func (s *AutoAlertService) sendAlert(ctx context.Context, parkn model.Parkn, preferences model.Preferences) (model.SentAlert, error) {
+
+	sent := model.SentAlert{
+		PhoneNumber: parkn.PhoneNumber,
+		Channel:     parkn.Channel,
+		Delivery:    model.DeliveryText,
+		Parkn:       &parkn,
+	}
+
+	// the language is kept with the alert so snoozed and retried deliveries use it too
+	locale := i18n.Get(preferences.Language)
+	sent.Language = locale.Tag
+
+	var err error
+	data := i18n.ParknData(parkn)
+	if preferences.Delivery == model.DeliveryVoice {
+		sent.Delivery = model.DeliveryVoice
+		sent.Body = locale.Render(i18n.Voice, data)
+		sent.CallSid, err = s.caller.Call(ctx, parkn.PhoneNumber, locale.VoiceLanguage, sent.Body)
+		return sent, err
+	}
+
+	sent.Body = locale.Render(i18n.Alert, data)
+	sent.MessageSid, err = s.messenger.SendTemplate(ctx, parkn.PhoneNumber, parkn.Channel, sent.Body, s.alertTemplate(locale, data))
+	return sent, err
+}
+
+func (s *AutoAlertService) alertTemplate(locale *i18n.Locale, data i18n.Data) Template {
+	return Template{
+		ContentSid: s.templates.Alert,
+		Variables:  map[string]string{"1": locale.Render(i18n.AlertSubject, data)},
+	}
+}
+
+// reminderText is sent when a snoozed or unanswered alert comes back
+func reminderText(locale *i18n.Locale, alert model.SentAlert) string {
+	return locale.Render(i18n.Reminder, alertData(alert))
+}
+
+// retryFailed sends again the reminders in the retry queue that have come due. Ones for a sweep that has already
+// started are dead-lettered, and ones for alerts canceled in the meantime are dropped.
+func (s *AutoAlertService) retryFailed(ctx context.Context) {
+	for {
+		now := time.Now()
+		retry, found, err := s.retryQueue.ClaimDue(ctx, now)
+		if err != nil {
+			s.logger.Error(ctx, errRetryFailed, err)
+			return
+		}
+		if !found {
+			return
+		}
+
+		phoneNumber := retry.PhoneNumber
+		if !now.Before(retry.Parkn.MoveByDate) {
+			err = s.retryQueue.DeadLetter(ctx, retry, errors.New(errSweepStarted))
+			if err != nil {
+				s.logger.Error(ctx, errRetryFailed, err, "phoneNumber", phoneNumber)
+			}
+			continue
+		}
+
+		awaiting, err := s.service.AwaitingReminders(ctx, retry.Parkn)
+		if err != nil {
+			s.retryLater(ctx, retry, err)
+			continue
+		}
+		if !awaiting {
+			s.logger.Info(ctx, msgRetryDropped, "phoneNumber", phoneNumber)
+			s.removeRetry(ctx, retry)
+			continue
+		}
+
+		preferences := s.userPreferences(ctx, phoneNumber)
+		if until, held := quietHold(preferences, retry.Parkn.MoveByDate, now); held {
+			s.logger.Info(ctx, msgQuietHeld, "phoneNumber", phoneNumber, "until", until.Format(time.RFC3339))
+			err = s.retryQueue.Hold(ctx, retry.ID, until)
+			if err != nil {
+				s.logger.Error(ctx, errHoldAlert, err, "phoneNumber", phoneNumber)
+			}
+			continue
+		}
+
+		sent, err := s.sendAlert(ctx, retry.Parkn, preferences)
+		if errors.Is(err, ErrRecipientSuppressed) {
+			s.logger.Info(ctx, msgSuppressedDropped, "phoneNumber", phoneNumber)
+			s.removeRetry(ctx, retry)
+			continue
+		}
+		if err != nil {
+			s.retryLater(ctx, retry, err)
+			continue
+		}
+
+		s.logger.Info(ctx, msgRetrySent, "phoneNumber", phoneNumber, "attempt", strconv.Itoa(retry.Attempts+1))
+		err = s.service.MarkSent(ctx, retry.Parkn)
+		if err != nil {
+			s.logger.Error(ctx, errMarkingSent, err, "phoneNumber", phoneNumber, "id", retry.Parkn.ID.Hex())
+		}
+		err = s.alertLog.Record(ctx, sent)
+		if err != nil {
+			s.logger.Error(ctx, errRecordingSent, err, "phoneNumber", phoneNumber)
+		}
+		s.removeRetry(ctx, retry)
+	}
+}
+
+// retryLater records a failed retry, which the queue schedules again with backoff or dead-letters
+func (s *AutoAlertService) retryLater(ctx context.Context, retry model.AlertRetry, cause error) {
+	s.logger.Error(ctx, errRetryFailed, cause, "phoneNumber", retry.PhoneNumber, "attempt", strconv.Itoa(retry.Attempts+1))
+	dead, err := s.retryQueue.Failed(ctx, retry, cause)
+	if err != nil {
+		s.logger.Error(ctx, errRetryFailed, err, "phoneNumber", retry.PhoneNumber)
+		return
+	}
+	if dead {
+		s.logger.Error(ctx, errGaveUp, cause, "phoneNumber", retry.PhoneNumber, "attempts", strconv.Itoa(retry.Attempts+1))
+	}
+}
+
+func (s *AutoAlertService) removeRetry(ctx context.Context, retry model.AlertRetry) {
+	err := s.retryQueue.Remove(ctx, retry.ID)
+	if err != nil {
+		s.logger.Error(ctx, errRetryFailed, err, "phoneNumber", retry.PhoneNumber)
+	}
+}
+
+// resendSnoozed sends a follow-up for every snoozed alert that has come due. One that fails to send is snoozed
+// again for redeliveryRetryDelay, until its sweep starts.
+func (s *AutoAlertService) resendSnoozed(ctx context.Context) {
+	for {
+		now := time.Now()
+		alert, found, err := s.alertLog.ClaimDueSnooze(ctx, now)
+		if err != nil {
+			s.logger.Error(ctx, errSnoozedAlert, err)
+			return
+		}
+		if !found {
+			return
+		}
+
+		if until, held := s.alertHold(ctx, alert, now); held {
+			err = s.alertLog.Resnooze(ctx, alert.ID, until)
+			if err != nil {
+				s.logger.Error(ctx, errHoldAlert, err, "phoneNumber", alert.PhoneNumber)
+			}
+			continue
+		}
+
+		locale := i18n.Get(alert.Language)
+		if alert.Delivery == model.DeliveryVoice {
+			err = s.call(ctx, &alert, locale, alert.Body)
 		} else {
-			s.logger.Info(ctx, msgAlertSuccessful, "phoneNumber", phoneNumber)
-			err = s.service.DeleteParkn(ctx, phoneNumber)
+			err = s.text(ctx, &alert, locale, reminderText(locale, alert), Template{ContentSid: s.templates.Reminder})
+		}
+		if errors.Is(err, ErrRecipientSuppressed) {
+			s.logger.Info(ctx, msgSuppressedDropped, "phoneNumber", alert.PhoneNumber)
+			continue
+		}
+		if err != nil {
+			s.logger.Error(ctx, errSnoozedAlert, err, "phoneNumber", alert.PhoneNumber)
+			if !sweepStarted(alert, now) {
+				err = s.alertLog.Resnooze(ctx, alert.ID, now.Add(redeliveryRetryDelay))
+				if err != nil {
+					s.logger.Error(ctx, errSnoozedAlert, err, "phoneNumber", alert.PhoneNumber)
+				}
+			}
+			continue
+		}
+		s.recordRedelivery(ctx, alert)
+		s.logger.Info(ctx, msgSnoozedResent, "phoneNumber", alert.PhoneNumber)
+	}
+}
+
+// retryDeliveries tries again for alerts that didn't get through. Unanswered calls are retried and fall back to a
+// text once the attempts run out. Undelivered texts are resent and then escalated to a single call, and an alert
+// that fails both ways, or whose sweep starts first, is given up on.
+func (s *AutoAlertService) retryDeliveries(ctx context.Context) {
+	for {
+		now := time.Now()
+		alert, found, err := s.alertLog.ClaimDueRetry(ctx, now)
+		if err != nil {
+			s.logger.Error(ctx, errRetryDelivery, err)
+			return
+		}
+		if !found {
+			return
+		}
+
+		if sweepStarted(alert, now) {
+			s.giveUp(ctx, alert, errors.New(errSweepStarted))
+			continue
+		}
+
+		// this covers the call a text that was never delivered escalates to as well
+		if until, held := s.alertHold(ctx, alert, now); held {
+			err = s.alertLog.HoldRetry(ctx, alert.ID, until)
 			if err != nil {
-				unsuccessful = append(unsuccessful, phoneNumber)
-				s.logger.Error(ctx, errDeleteParkn, err, "phoneNumber", phoneNumber)
-			} else {
-				s.logger.Info(ctx, msgDeleteSuccessful, "phoneNumber", phoneNumber)
-				successful = append(successful, phoneNumber)
+				s.logger.Error(ctx, errHoldAlert, err, "phoneNumber", alert.PhoneNumber)
 			}
+			continue
+		}
+
+		locale := i18n.Get(alert.Language)
+		if alert.Delivery == model.DeliveryVoice {
+			s.retryCall(ctx, alert, locale)
+		} else {
+			s.retryText(ctx, alert, locale)
 		}
 	}
+}
 
-	s.logger.Info(ctx, msgAlertComplete, "successful", strings.Join(successful, ", "), "unsuccessful", strings.Join(unsuccessful, ", "))
+func (s *AutoAlertService) retryCall(ctx context.Context, alert model.SentAlert, locale *i18n.Locale) {
+
+	if alert.CallAttempts >= s.maxCallAttempts {
+		err := s.text(ctx, &alert, locale, reminderText(locale, alert), Template{ContentSid: s.templates.Reminder})
+		if err != nil {
+			s.redeliveryFailed(ctx, alert, model.DeliveryText, err)
+			return
+		}
+		s.recordRedelivery(ctx, alert)
+		s.logger.Info(ctx, msgCallFallback, "phoneNumber", alert.PhoneNumber)
+		return
+	}
+
+	err := s.call(ctx, &alert, locale, alert.Body)
+	if err != nil {
+		s.redeliveryFailed(ctx, alert, model.DeliveryVoice, err)
+		return
+	}
+	s.recordRedelivery(ctx, alert)
+	s.logger.Info(ctx, msgCallRetried, "phoneNumber", alert.PhoneNumber, "attempt", strconv.Itoa(alert.CallAttempts+1))
+}
+
+func (s *AutoAlertService) retryText(ctx context.Context, alert model.SentAlert, locale *i18n.Locale) {
+
+	if alert.TextAttempts < s.maxTextAttempts {
+		err := s.text(ctx, &alert, locale, alert.Body, s.alertTemplate(locale, alertData(alert)))
+		if err != nil {
+			s.redeliveryFailed(ctx, alert, model.DeliveryText, err)
+			return
+		}
+		s.recordRedelivery(ctx, alert)
+		s.logger.Info(ctx, msgTextRetried, "phoneNumber", alert.PhoneNumber, "attempt", strconv.Itoa(alert.TextAttempts+1))
+		return
+	}
+
+	// a call reaches phones the carrier won't deliver texts to, but only once so a call that falls back to a text
+	// that fails again ends here
+	if alert.CallAttempts == 0 {
+		err := s.call(ctx, &alert, locale, locale.Render(i18n.Voice, alertData(alert)))
+		if err != nil {
+			s.redeliveryFailed(ctx, alert, model.DeliveryVoice, err)
+			return
+		}
+		s.recordRedelivery(ctx, alert)
+		s.logger.Info(ctx, msgTextEscalated, "phoneNumber", alert.PhoneNumber)
+		return
+	}
+
+	s.giveUp(ctx, alert, errors.New(errUndeliverable))
 }
 
-func (s *AutoAlertService) sendAlert(phoneNumber string) error {
+// redeliveryFailed schedules another try redeliveryRetryDelay after sending a claimed alert by delivery failed, so it
+// isn't left marked sent. Recipients who opted out are not tried again.
+func (s *AutoAlertService) redeliveryFailed(ctx context.Context, alert model.SentAlert, delivery string, cause error) {
 
-	params := &twilioApi.CreateMessageParams{
-		To:   s.strPtr(phoneNumber),
-		From: s.strPtr(s.twilioNumber),
-		Body: s.strPtr(alertMsg),
+	if errors.Is(cause, ErrRecipientSuppressed) {
+		s.logger.Info(ctx, msgSuppressedDropped, "phoneNumber", alert.PhoneNumber)
+		return
 	}
 
-	_, err := s.twilio.Api.CreateMessage(params)
+	s.logger.Error(ctx, errRetryDelivery, cause, "phoneNumber", alert.PhoneNumber, "delivery", delivery)
+	err := s.alertLog.ScheduleRetry(ctx, alert.ID, delivery, time.Now().Add(redeliveryRetryDelay))
+	if err != nil {
+		s.logger.Error(ctx, errRetryDelivery, err, "phoneNumber", alert.PhoneNumber)
+	}
+}
 
-	return err
+// giveUp marks an alert failed so it is retried no more
+func (s *AutoAlertService) giveUp(ctx context.Context, alert model.SentAlert, cause error) {
+	err := s.alertLog.MarkFailed(ctx, alert.ID)
+	if err != nil {
+		s.logger.Error(ctx, errRecordingSent, err, "phoneNumber", alert.PhoneNumber)
+	}
+	s.logger.Error(ctx, errGaveUp, cause, "phoneNumber", alert.PhoneNumber, "deliveryStatus", alert.DeliveryStatus)
 }
 
-func (s *AutoAlertService) strPtr(str string) *string {
-	return &str
+// text sends an alert again as a text, updating it with what was sent
+func (s *AutoAlertService) text(ctx context.Context, alert *model.SentAlert, locale *i18n.Locale, body string, template Template) error {
+	messageSid, err := s.messenger.SendTemplate(ctx, alert.PhoneNumber, alert.Channel, body, template)
+	if err != nil {
+		return err
+	}
+	alert.Delivery = model.DeliveryText
+	alert.MessageSid = messageSid
+	alert.CallSid = ""
+	alert.Body = body
+	return nil
+}
+
+// call sends an alert again as a call, updating it with what was said
+func (s *AutoAlertService) call(ctx context.Context, alert *model.SentAlert, locale *i18n.Locale, body string) error {
+	callSid, err := s.caller.Call(ctx, alert.PhoneNumber, locale.VoiceLanguage, body)
+	if err != nil {
+		return err
+	}
+	alert.Delivery = model.DeliveryVoice
+	alert.CallSid = callSid
+	alert.MessageSid = ""
+	alert.Body = body
+	return nil
+}
+
+func (s *AutoAlertService) recordRedelivery(ctx context.Context, alert model.SentAlert) {
+	err := s.alertLog.RecordRedelivery(ctx, alert)
+	if err != nil {
+		s.logger.Error(ctx, errRecordingSent, err, "phoneNumber", alert.PhoneNumber)
+	}
+}
+
+// alertHold reports when an alert sent before should go out again if now is in the user's quiet hours. Alerts sent
+// before their parkn was kept with them have no sweep to be too late for, so they wait for quiet hours to end.
+func (s *AutoAlertService) alertHold(ctx context.Context, alert model.SentAlert, now time.Time) (time.Time, bool) {
+
+	deadline := now.Add(model.MaxLeadTime)
+	if alert.Parkn != nil {
+		deadline = alert.Parkn.MoveByDate
+	}
+
+	until, held := quietHold(s.userPreferences(ctx, alert.PhoneNumber), deadline, now)
+	if held {
+		s.logger.Info(ctx, msgQuietHeld, "phoneNumber", alert.PhoneNumber, "until", until.Format(time.RFC3339))
+	}
+	return until, held
+}
+
+// quietHold reports when something due now should be sent instead, and false when that's now because it isn't in
+// the user's quiet hours, or waiting would be too late for the sweep at deadline
+func quietHold(preferences model.Preferences, deadline, now time.Time) (time.Time, bool) {
+
+	if preferences.QuietHours == nil {
+		return now, false
+	}
+
+	loc, _ := time.LoadLocation("EST")
+	until := preferences.QuietHours.Hold(now, deadline, loc)
+	return until, until.After(now)
+}
+
+// sweepStarted reports whether the sweep an alert was for has begun, after which there's no point sending it again.
+// Alerts sent before their parkn was kept with them can't tell and never have.
+func sweepStarted(alert model.SentAlert, now time.Time) bool {
+	return alert.Parkn != nil && !now.Before(alert.Parkn.MoveByDate)
+}
+
+// alertData is what the alert's templates can use. Alerts sent before their parkn was kept with them have only the defaults.
+func alertData(alert model.SentAlert) i18n.Data {
+	if alert.Parkn == nil {
+		return i18n.Data{}
+	}
+	return i18n.ParknData(*alert.Parkn)
 }
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+	"github.com/twilio/twilio-go/twiml"
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+)
+
+const (
+	errPlacingCall = "error while placing call"
+
+	msgCallPlaced = "successfully placed call"
+
+	// the message is read twice in case the first words are missed while picking up
+	sayLoop = "2"
+
+	// seconds to ring before Twilio reports no-answer
+	callRingTimeout = 30
+)
+
+// Caller places voice calls through the Twilio REST API that read a message aloud
+type Caller struct {
+	logger            logger.Logger
+	twilio            *twilio.RestClient
+	twilioNumber      string
+	statusCallbackUrl string
+	suppressions      ISuppressionList
+}
+
+// NewCaller returns a Caller. Without a statusCallbackUrl Twilio never reports unanswered calls, so they are not retried.
+func NewCaller(logger logger.Logger, twilio *twilio.RestClient, twilioNumber, statusCallbackUrl string, suppressions ISuppressionList) *Caller {
+	return &Caller{
+		logger:            logger,
+		twilio:            twilio,
+		twilioNumber:      twilioNumber,
+		statusCallbackUrl: statusCallbackUrl,
+		suppressions:      suppressions,
+	}
+}
+
+// Call rings the phone number and reads the message with TwiML <Say> in the given language, such as "es-US".
+// It returns the call SID, or ErrRecipientSuppressed without calling when the number opted out.
+func (c *Caller) Call(ctx context.Context, phoneNumber, language, message string) (string, error) {
+
+	err := checkSuppressed(ctx, c.suppressions, phoneNumber)
+	if err != nil {
+		return "", errs.WrapError(errPlacingCall, err)
+	}
+
+	response, err := twiml.Voice([]twiml.Element{
+		&twiml.VoiceSay{Message: message, Loop: sayLoop, Language: language},
+	})
+	if err != nil {
+		return "", errs.WrapError(errPlacingCall, err)
+	}
+
+	params := &twilioApi.CreateCallParams{}
+	params.SetTo(phoneNumber)
+	params.SetFrom(c.twilioNumber)
+	params.SetTwiml(response)
+	params.SetTimeout(callRingTimeout)
+	if len(c.statusCallbackUrl) > 0 {
+		params.SetStatusCallback(c.statusCallbackUrl)
+		params.SetStatusCallbackMethod("POST")
+		params.SetStatusCallbackEvent([]string{"completed"})
+	}
+
+	call, err := c.twilio.Api.CreateCall(params)
+	if err != nil {
+		return "", errs.WrapError(errPlacingCall, err)
+	}
+
+	callSid := ""
+	if call.Sid != nil {
+		callSid = *call.Sid
+	}
+
+	c.logger.Debug(ctx, msgCallPlaced, "phoneNumber", phoneNumber, "callSid", callSid)
+	return callSid, nil
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -14,6 +16,11 @@ import (
 
 const (
 	errGettingImageFromUrl = "error occurred while getting image from url"
+	errMediaStatus         = "media request failed with status"
+	errMediaTooLarge       = "media larger than the maximum size"
+
+	// maxMediaBytes bounds a downloaded attachment. WhatsApp allows media up to 16MB, MMS far less.
+	maxMediaBytes = 16 << 20
 )
 
 type TwilioCreds struct {
@@ -21,6 +28,12 @@ type TwilioCreds struct {
 	TwilioToken string
 }
 
+type Media struct {
+	Content     []byte
+	ContentType string
+	Image       *vision.Image
+}
+
 type Client struct {
 	logger        logger.Logger
 	httpClient    http.Client
@@ -37,34 +50,60 @@ func NewHttpClient(logger logger.Logger, textExtractor ITextExtractor, creds twi
 	}
 }
 
-func (c *Client) FetchMedia(ctx context.Context, mediaUrl string) (*vision.Image, error) {
+// FetchMedia downloads the media at mediaUrl and returns the original bytes along with the converted vision image
+func (c *Client) FetchMedia(ctx context.Context, mediaUrl string) (*Media, error) {
+
+	body, contentType, err := c.FetchFile(ctx, mediaUrl)
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest(http.MethodGet, mediaUrl, nil)
+	imgEncoding := base64.StdEncoding.EncodeToString(body)
+	img, err := c.textExtractor.ConvertToVisionImage(ctx, imgEncoding)
 	if err != nil {
 		return nil, errs.WrapError(errGettingImageFromUrl, err)
 	}
 
+	media := &Media{
+		Content:     body,
+		ContentType: contentType,
+		Image:       img,
+	}
+
+	return media, nil
+}
+
+// FetchFile downloads the attachment at mediaUrl as is, for attachments that are not images such as vCards
+func (c *Client) FetchFile(ctx context.Context, mediaUrl string) ([]byte, string, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaUrl, nil)
+	if err != nil {
+		return nil, "", errs.WrapError(errGettingImageFromUrl, err)
+	}
+
 	req.Header.Add("Authorization", c.basicAuth())
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, errs.WrapError(errGettingImageFromUrl, err)
+		return nil, "", errs.WrapError(errGettingImageFromUrl, err)
 	}
 
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errs.WrapError(errGettingImageFromUrl, err)
+	// error bodies such as Twilio's for an expired or unauthorized url are never passed on as the attachment
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, "", errs.WrapError(errGettingImageFromUrl, fmt.Errorf("%s: %d", errMediaStatus, resp.StatusCode))
 	}
 
-	imgEncoding := base64.StdEncoding.EncodeToString(body)
-	img, err := c.textExtractor.ConvertToVisionImage(ctx, imgEncoding)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaBytes+1))
 	if err != nil {
-		return nil, errs.WrapError(errGettingImageFromUrl, err)
+		return nil, "", errs.WrapError(errGettingImageFromUrl, err)
+	}
+	if len(body) > maxMediaBytes {
+		return nil, "", errs.WrapError(errGettingImageFromUrl, errors.New(errMediaTooLarge))
 	}
 
-	return img, nil
+	return body, resp.Header.Get("Content-Type"), nil
 }
 
 func (c *Client) basicAuth() string {
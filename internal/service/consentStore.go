@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	errCheckSuppression = "error while checking suppression list"
+	errOptOut           = "error while recording opt-out"
+	errOptIn            = "error while recording opt-in"
+	errConsentHistory   = "error while getting consent history"
+	errSuppressed       = "recipient opted out"
+
+	msgOptedOut = "phone number opted out"
+	msgOptedIn  = "phone number opted in"
+)
+
+var (
+	// ErrRecipientSuppressed is returned instead of sending to a number that opted out
+	ErrRecipientSuppressed = errors.New(errSuppressed)
+)
+
+type ISuppressionDal interface {
+	Get(ctx context.Context, filter interface{}) ([]model.Suppression, error)
+	UpsertOne(ctx context.Context, filter interface{}, input model.Suppression) error
+	DeleteOne(ctx context.Context, filter interface{}) (int64, error)
+}
+
+type IConsentEventDal interface {
+	CreateOne(ctx context.Context, input model.ConsentEvent) (string, error)
+	Get(ctx context.Context, filter interface{}) ([]model.ConsentEvent, error)
+}
+
+// ConsentStore keeps the suppression list of numbers that texted STOP or a similar keyword, along with a
+// permanent record of every opt-out and opt-in
+type ConsentStore struct {
+	logger       logger.Logger
+	suppressions ISuppressionDal
+	events       IConsentEventDal
+}
+
+func NewConsentStore(logger logger.Logger, suppressions ISuppressionDal, events IConsentEventDal) *ConsentStore {
+	return &ConsentStore{
+		logger:       logger,
+		suppressions: suppressions,
+		events:       events,
+	}
+}
+
+// IsSuppressed reports whether the phone number opted out
+func (c *ConsentStore) IsSuppressed(ctx context.Context, phoneNumber string) (bool, error) {
+	found, err := c.suppressions.Get(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		return false, errs.WrapError(errCheckSuppression, err)
+	}
+	return len(found) > 0, nil
+}
+
+// OptOut adds the phone number to the suppression list and records the keyword it used
+func (c *ConsentStore) OptOut(ctx context.Context, phoneNumber, channel, keyword, messageSid string) error {
+
+	now := time.Now()
+	suppression := model.Suppression{
+		PhoneNumber:  phoneNumber,
+		Keyword:      keyword,
+		SuppressedAt: now,
+	}
+
+	err := c.suppressions.UpsertOne(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}}, suppression)
+	if err != nil {
+		return errs.WrapError(errOptOut, err)
+	}
+
+	err = c.record(ctx, model.ConsentEvent{
+		PhoneNumber: phoneNumber,
+		Channel:     channel,
+		Action:      model.ConsentOptOut,
+		Keyword:     keyword,
+		MessageSid:  messageSid,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return errs.WrapError(errOptOut, err)
+	}
+
+	c.logger.Info(ctx, msgOptedOut, "phoneNumber", phoneNumber, "keyword", keyword)
+	return nil
+}
+
+// OptIn removes the phone number from the suppression list and records the keyword it used
+func (c *ConsentStore) OptIn(ctx context.Context, phoneNumber, channel, keyword, messageSid string) error {
+
+	_, err := c.suppressions.DeleteOne(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		return errs.WrapError(errOptIn, err)
+	}
+
+	err = c.record(ctx, model.ConsentEvent{
+		PhoneNumber: phoneNumber,
+		Channel:     channel,
+		Action:      model.ConsentOptIn,
+		Keyword:     keyword,
+		MessageSid:  messageSid,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return errs.WrapError(errOptIn, err)
+	}
+
+	c.logger.Info(ctx, msgOptedIn, "phoneNumber", phoneNumber, "keyword", keyword)
+	return nil
+}
+
+// History returns every opt-out and opt-in recorded for the phone number, oldest first
+func (c *ConsentStore) History(ctx context.Context, phoneNumber string) ([]model.ConsentEvent, error) {
+	events, err := c.events.Get(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		return nil, errs.WrapError(errConsentHistory, err)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+
+	return events, nil
+}
+
+func (c *ConsentStore) record(ctx context.Context, event model.ConsentEvent) error {
+	_, err := c.events.CreateOne(ctx, event)
+	return err
+}
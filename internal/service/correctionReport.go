@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	errCorrectionReport = "error while building correction report"
+
+	// each phrase lists at most this many distinct original and corrected readings
+	maxReportReadings = 5
+
+	// corrections of alerts created from text the date sniper matched nothing in
+	unmatchedPhrase = "(no phrase matched)"
+)
+
+type ICorrectionReportDal interface {
+	Get(ctx context.Context, filter interface{}) ([]model.Correction, error)
+}
+
+// CorrectionReport summarizes user corrections so the date sniper's patterns can be improved where they misread most
+type CorrectionReport struct {
+	logger     logger.Logger
+	repository ICorrectionReportDal
+}
+
+func NewCorrectionReport(logger logger.Logger, repository ICorrectionReportDal) *CorrectionReport {
+	return &CorrectionReport{
+		logger:     logger,
+		repository: repository,
+	}
+}
+
+// MostCorrectedPhrases returns the phrases corrected since the given time, most corrected first
+func (r *CorrectionReport) MostCorrectedPhrases(ctx context.Context, since time.Time, limit int) ([]model.CorrectedPhrase, error) {
+
+	filter := bson.D{
+		{Key: "createdAt", Value: bson.D{
+			{Key: "$gte", Value: primitive.NewDateTimeFromTime(since)},
+		}},
+	}
+
+	corrections, err := r.repository.Get(ctx, filter)
+	if err != nil {
+		return nil, errs.WrapError(errCorrectionReport, err)
+	}
+
+	byPhrase := make(map[string]*model.CorrectedPhrase)
+	for _, correction := range corrections {
+		phrase := correction.Phrase
+		if len(phrase) == 0 {
+			phrase = unmatchedPhrase
+		}
+
+		entry, exists := byPhrase[phrase]
+		if !exists {
+			entry = &model.CorrectedPhrase{Phrase: phrase, OriginalReads: []string{}, CorrectedReads: []string{}}
+			byPhrase[phrase] = entry
+		}
+
+		entry.Count++
+		entry.OriginalReads = appendReading(entry.OriginalReads, correction.OriginalSchedule.String())
+		entry.CorrectedReads = appendReading(entry.CorrectedReads, correction.CorrectedSchedule.String())
+		if correction.CreatedAt.After(entry.LastCorrectedAt) {
+			entry.LastCorrectedAt = correction.CreatedAt
+		}
+	}
+
+	report := make([]model.CorrectedPhrase, 0, len(byPhrase))
+	for _, entry := range byPhrase {
+		report = append(report, *entry)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Count != report[j].Count {
+			return report[i].Count > report[j].Count
+		}
+		return report[i].Phrase < report[j].Phrase
+	})
+
+	if limit > 0 && len(report) > limit {
+		report = report[:limit]
+	}
+
+	return report, nil
+}
+
+func appendReading(readings []string, reading string) []string {
+	if len(readings) >= maxReportReadings || slices.Contains(readings, reading) {
+		return readings
+	}
+	return append(readings, reading)
+}
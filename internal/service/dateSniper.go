@@ -2,43 +2,41 @@ package service
 
 import (
 	"context"
-	"errors"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/teambition/rrule-go"
 	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/i18n"
 	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
 )
 
 const (
 	errSnipingDate           = "error while finding date"
-	errNoFrequencyFound      = "no frequency detected in extracted text from image"
+	errNoFrequencyFound      = "no frequency detected in schedule text"
 	errCalculatingOccurrence = "error while calculating next occurrence"
 )
 
-type frequency struct {
-	dayOfWeek        int
-	firstOccOfMonth  int
-	secondOccOfMonth int
-}
-
 var (
-	possibleFrequencies = map[string]frequency{
-		"1ST & 3RD MONDAY":    {1, 1, 3},
-		"2ND & 4TH MONDAY":    {1, 2, 4},
-		"1ST & 3RD TUESDAY":   {2, 1, 3},
-		"2ND & 4TH TUESDAY":   {2, 2, 4},
-		"1ST & 3RD WEDNESDAY": {3, 1, 3},
-		"2ND & 4TH WEDNESDAY": {3, 2, 4},
-		"1ST & 3RD THURSDAY":  {4, 1, 3},
-		"2ND & 4TH THURSDAY":  {4, 2, 4},
-		"1ST & 3RD FRIDAY":    {5, 1, 3},
-		"2ND & 4TH FRIDAY":    {5, 2, 4},
-		"1ST & 3RD SATURDAY":  {6, 1, 3},
-		"2ND & 4TH SATURDAY":  {6, 2, 4},
-		"1ST & 3RD SUNDAY":    {7, 1, 3},
-		"2ND & 4TH SUNDAY":    {7, 2, 4},
+	ordinals = map[string]int{
+		"1ST": 1, "FIRST": 1,
+		"2ND": 2, "SECOND": 2,
+		"3RD": 3, "THIRD": 3,
+		"4TH": 4, "FOURTH": 4,
+		"5TH": 5, "FIFTH": 5,
+	}
+
+	weekdays = map[string]int{
+		"MONDAY": 1, "MON": 1,
+		"TUESDAY": 2, "TUES": 2, "TUE": 2,
+		"WEDNESDAY": 3, "WED": 3,
+		"THURSDAY": 4, "THURS": 4, "THUR": 4, "THU": 4,
+		"FRIDAY": 5, "FRI": 5,
+		"SATURDAY": 6, "SAT": 6,
+		"SUNDAY": 7, "SUN": 7,
 	}
 
 	weekdayToRule = map[int]interface{}{
@@ -50,6 +48,14 @@ var (
 		6: rrule.SA,
 		7: rrule.SU,
 	}
+
+	ordinalPattern   = `(?:1ST|2ND|3RD|4TH|5TH|FIRST|SECOND|THIRD|FOURTH|FIFTH)`
+	weekdayPattern   = `(?:MONDAY|TUESDAY|WEDNESDAY|THURSDAY|FRIDAY|SATURDAY|SUNDAY|TUES|THURS|THUR|MON|TUE|WED|THU|FRI|SAT|SUN)`
+	frequencyRegex   = regexp.MustCompile(`\b(` + ordinalPattern + `(?:\s*(?:&|AND|\+|,)\s*` + ordinalPattern + `)*)\s+(` + weekdayPattern + `)\b`)
+	ordinalRegex     = regexp.MustCompile(ordinalPattern)
+	timeWindowRegex  = regexp.MustCompile(`\b(\d{1,2})(?::(\d{2}))?\s*(AM|PM)?\s*(?:-|–|—|TO)\s*(\d{1,2})(?::(\d{2}))?\s*(AM|PM)`)
+	whitespaceRegex  = regexp.MustCompile(`\s+`)
+	meridiemReplacer = strings.NewReplacer("A.M.", "AM", "P.M.", "PM")
 )
 
 type DateSniper struct {
@@ -62,61 +68,186 @@ func NewDateSniper(logger logger.Logger) *DateSniper {
 	}
 }
 
-// SnipeDate takes extracted image text and finds next street sweeping occurrence
-func (d *DateSniper) SnipeDate(ctx context.Context, str string) (time.Time, error) {
+// SnipeDate takes extracted image text or a typed schedule and finds the schedule and its next street sweeping occurrence
+func (d *DateSniper) SnipeDate(ctx context.Context, str string) (model.Schedule, time.Time, error) {
 
-	strArr := strings.Split(strings.ToUpper(str), "\n")
+	text := normalizeScheduleText(str)
 
-	freq, found := d.getFreq(strArr)
+	schedule, found := d.getFreq(text)
 	if !found {
-		err := errors.New(errNoFrequencyFound)
+		err := NewAdvice(errNoFrequencyFound, i18n.AdviceNoFrequency)
 		d.logger.Error(ctx, errSnipingDate, err)
-		return time.Time{}, errs.WrapError(errSnipingDate, err)
+		return model.Schedule{}, time.Time{}, errs.WrapError(errSnipingDate, err)
 	}
 
-	nextOccurrence, err := d.findNextOccurrence(freq)
+	d.getWindow(text, &schedule)
+
+	nextOccurrence, err := d.findNextOccurrence(schedule)
 	if err != nil {
 		d.logger.Error(ctx, errSnipingDate, err)
-		return time.Time{}, errs.WrapError(errSnipingDate, err)
+		return model.Schedule{}, time.Time{}, errs.WrapError(errSnipingDate, err)
 	}
-	return nextOccurrence, nil
+	return schedule, nextOccurrence, nil
 }
 
-func (d *DateSniper) findNextOccurrence(freq frequency) (time.Time, error) {
+// findNextOccurrence returns the start of the next sweep, or midnight of the sweep day when no window is known
+func (d *DateSniper) findNextOccurrence(schedule model.Schedule) (time.Time, error) {
 
-	loc, err := time.LoadLocation("EST")
+	rule, loc, err := d.rule(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now().In(loc)
+	today := now.Add(time.Hour * -24)
+	nextOccurrence := d.truncateToDay(rule.After(today, true).In(loc))
+
+	if !schedule.HasWindow {
+		return nextOccurrence, nil
+	}
+
+	// today's sweep is only useful while it has not ended yet
+	if nextOccurrence.Add(time.Duration(schedule.WindowEnd) * time.Minute).Before(now) {
+		nextOccurrence = d.truncateToDay(rule.After(nextOccurrence, false).In(loc))
+	}
+
+	return nextOccurrence.Add(time.Duration(schedule.WindowStart) * time.Minute), nil
+}
+
+// NextOccurrence returns the start of the first sweep on a day after the given time, which is where a recurring
+// alert moves once the sweep it was for has been alerted
+func (d *DateSniper) NextOccurrence(schedule model.Schedule, after time.Time) (time.Time, error) {
+
+	rule, loc, err := d.rule(schedule)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	rruleWeekday := weekdayToRule[freq.dayOfWeek]
+	nextOccurrence := d.truncateToDay(rule.After(d.truncateToDay(after.In(loc)), false).In(loc))
+	return nextOccurrence.Add(time.Duration(schedule.WindowStart) * time.Minute), nil
+}
+
+// rule is the schedule as a monthly recurrence whose occurrences fall at midnight EST on sweep days
+func (d *DateSniper) rule(schedule model.Schedule) (*rrule.RRule, *time.Location, error) {
+
+	loc, err := time.LoadLocation("EST")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rruleWeekday := weekdayToRule[schedule.DayOfWeek]
 	startDate := time.Date(2020, 1, 1, 0, 0, 0, 0, loc)
 
 	rule, err := rrule.NewRRule(rrule.ROption{
 		Freq:      rrule.MONTHLY,
 		Dtstart:   startDate,
 		Byweekday: []rrule.Weekday{rruleWeekday.(rrule.Weekday)},
-		Bysetpos:  []int{freq.firstOccOfMonth, freq.secondOccOfMonth},
+		Bysetpos:  schedule.Occurrences,
 	})
-
 	if err != nil {
-		return time.Time{}, err
+		return nil, nil, err
 	}
 
-	today := time.Now().Add(time.Hour * -24).In(loc)
-	nextOccurrence := rule.After(today, true).In(loc)
-	return d.truncateToDay(nextOccurrence), nil
+	return rule, loc, nil
 }
 
-func (d *DateSniper) getFreq(strArr []string) (frequency, bool) {
-	for _, str := range strArr {
-		if freq, exists := possibleFrequencies[str]; exists {
-			return freq, true
+// MatchedPhrase returns the part of the text the schedule was read from, the frequency followed by the time
+// window when one was found, so misreads of the same wording can be grouped together
+func (d *DateSniper) MatchedPhrase(str string) string {
+
+	text := normalizeScheduleText(str)
+
+	phrase := frequencyRegex.FindString(text)
+	if len(phrase) == 0 {
+		return ""
+	}
+	if window := timeWindowRegex.FindString(text); len(window) > 0 {
+		phrase += " " + window
+	}
+	return phrase
+}
+
+func (d *DateSniper) getFreq(text string) (model.Schedule, bool) {
+
+	match := frequencyRegex.FindStringSubmatch(text)
+	if match == nil {
+		return model.Schedule{}, false
+	}
+
+	occurrences := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, ordinal := range ordinalRegex.FindAllString(match[1], -1) {
+		occurrence := ordinals[ordinal]
+		if !seen[occurrence] {
+			seen[occurrence] = true
+			occurrences = append(occurrences, occurrence)
 		}
 	}
-	return frequency{}, false
+
+	return model.Schedule{
+		DayOfWeek:   weekdays[match[2]],
+		Occurrences: occurrences,
+	}, true
+}
+
+// getWindow finds a time range such as "8AM-10AM", "9-11AM" or "8:30 AM TO 10 AM" and sets it on the schedule
+func (d *DateSniper) getWindow(text string, schedule *model.Schedule) {
+
+	match := timeWindowRegex.FindStringSubmatch(text)
+	if match == nil {
+		return
+	}
+
+	startHour, _ := strconv.Atoi(match[1])
+	startMinute, _ := strconv.Atoi(match[2])
+	endHour, _ := strconv.Atoi(match[4])
+	endMinute, _ := strconv.Atoi(match[5])
+	startMeridiem := match[3]
+	endMeridiem := match[6]
+
+	if startHour < 1 || startHour > 12 || endHour < 1 || endHour > 12 || startMinute > 59 || endMinute > 59 {
+		return
+	}
+
+	// "9-11AM" shares the end meridiem, while "11-1PM" crosses noon
+	if len(startMeridiem) == 0 {
+		startMeridiem = endMeridiem
+		if startHour%12 > endHour%12 {
+			startMeridiem = oppositeMeridiem(endMeridiem)
+		}
+	}
+
+	start := to24Hour(startHour, startMeridiem)*60 + startMinute
+	end := to24Hour(endHour, endMeridiem)*60 + endMinute
+	if end <= start {
+		return
+	}
+
+	schedule.HasWindow = true
+	schedule.WindowStart = start
+	schedule.WindowEnd = end
 }
 
 func (d *DateSniper) truncateToDay(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 }
+
+func normalizeScheduleText(str string) string {
+	text := meridiemReplacer.Replace(strings.ToUpper(str))
+	return whitespaceRegex.ReplaceAllString(text, " ")
+}
+
+func to24Hour(hour int, meridiem string) int {
+	hour = hour % 12
+	if meridiem == "PM" {
+		hour += 12
+	}
+	return hour
+}
+
+func oppositeMeridiem(meridiem string) string {
+	if meridiem == "PM" {
+		return "AM"
+	}
+	return "PM"
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+)
+
+const (
+	errReverseGeocode = "error while reverse geocoding"
+
+	// Nominatim's usage policy asks every application to identify itself
+	geocoderUserAgent = "parkn"
+)
+
+type nominatimResponse struct {
+	Address struct {
+		Road string `json:"road"`
+	} `json:"address"`
+}
+
+// Geocoder looks up the street at a coordinate with a Nominatim compatible reverse geocoding endpoint
+type Geocoder struct {
+	logger     logger.Logger
+	httpClient http.Client
+	reverseUrl string
+}
+
+func NewGeocoder(logger logger.Logger, reverseUrl string) *Geocoder {
+	return &Geocoder{
+		logger:     logger,
+		httpClient: http.Client{},
+		reverseUrl: reverseUrl,
+	}
+}
+
+func (g *Geocoder) StreetAt(ctx context.Context, latitude, longitude float64) (string, error) {
+
+	params := url.Values{}
+	params.Set("format", "jsonv2")
+	params.Set("lat", strconv.FormatFloat(latitude, 'f', -1, 64))
+	params.Set("lon", strconv.FormatFloat(longitude, 'f', -1, 64))
+	params.Set("zoom", "17")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.reverseUrl+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", errs.WrapError(errReverseGeocode, err)
+	}
+	req.Header.Set("User-Agent", geocoderUserAgent)
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", errs.WrapError(errReverseGeocode, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errs.WrapError(errReverseGeocode, fmt.Errorf("unexpected status %d", resp.StatusCode))
+	}
+
+	var result nominatimResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", errs.WrapError(errReverseGeocode, err)
+	}
+
+	return result.Address.Road, nil
+}
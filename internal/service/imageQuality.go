@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/willtowle1/parkn/internal/common/i18n"
+	"github.com/willtowle1/parkn/internal/common/logger"
+)
+
+const (
+	// images are box-sampled down to at most this many pixels on the long side before measuring
+	// so that thresholds do not depend on camera resolution
+	qualitySampleSize = 640
+
+	errImageTooSmall = "image resolution below minimum"
+	errImageTooDark  = "image brightness below minimum"
+	errImageBlurry   = "image sharpness below minimum"
+
+	msgImageQuality        = "image quality measured"
+	msgImageQualitySkipped = "image format not decodable, skipping quality check"
+)
+
+type ImageQualityThresholds struct {
+	MinWidth      int
+	MinHeight     int
+	MinBrightness float64
+	MinSharpness  float64
+}
+
+type ImageQualityChecker struct {
+	logger     logger.Logger
+	thresholds ImageQualityThresholds
+}
+
+func NewImageQualityChecker(logger logger.Logger, thresholds ImageQualityThresholds) *ImageQualityChecker {
+	return &ImageQualityChecker{
+		logger:     logger,
+		thresholds: thresholds,
+	}
+}
+
+// CheckQuality runs local checks for resolution, darkness and blur and returns an Advice
+// describing how to retake the photo when one of them fails. Formats that can't be decoded
+// here, such as GIF or WebP, skip the checks and are left to OCR.
+func (q *ImageQualityChecker) CheckQuality(ctx context.Context, content []byte) error {
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		q.logger.Debug(ctx, msgImageQualitySkipped, "reason", err.Error())
+		return nil
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() < q.thresholds.MinWidth || bounds.Dy() < q.thresholds.MinHeight {
+		return NewAdvice(errImageTooSmall, i18n.AdviceImageTooSmall)
+	}
+
+	gray := sampleGray(img, qualitySampleSize)
+
+	brightness := meanBrightness(gray)
+	sharpness := laplacianVariance(gray)
+
+	q.logger.Debug(ctx, msgImageQuality,
+		"width", fmt.Sprint(bounds.Dx()),
+		"height", fmt.Sprint(bounds.Dy()),
+		"brightness", fmt.Sprintf("%.1f", brightness),
+		"sharpness", fmt.Sprintf("%.1f", sharpness))
+
+	if brightness < q.thresholds.MinBrightness {
+		return NewAdvice(errImageTooDark, i18n.AdviceImageTooDark)
+	}
+	if sharpness < q.thresholds.MinSharpness {
+		return NewAdvice(errImageBlurry, i18n.AdviceImageBlurry)
+	}
+
+	return nil
+}
+
+// sampleGray box-samples the image into a grayscale grid with 0-255 luminance values
+func sampleGray(img image.Image, maxSize int) [][]float64 {
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(height*maxSize/width, 1)
+			width = maxSize
+		} else {
+			width = max(width*maxSize/height, 1)
+			height = maxSize
+		}
+	}
+
+	gray := make([][]float64, height)
+	for y := 0; y < height; y++ {
+		gray[y] = make([]float64, width)
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			gray[y][x] = float64(averageLuminance(img, x0, y0, x1, y1)) / 257
+		}
+	}
+
+	return gray
+}
+
+func meanBrightness(gray [][]float64) float64 {
+	var total float64
+	var count int
+	for _, row := range gray {
+		for _, value := range row {
+			total += value
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// laplacianVariance is the variance of the 3x3 laplacian response. Sharp edges produce a
+// high variance while blurred images produce a low one.
+func laplacianVariance(gray [][]float64) float64 {
+
+	var sum, sumSquares float64
+	var count int
+	for y := 1; y < len(gray)-1; y++ {
+		for x := 1; x < len(gray[y])-1; x++ {
+			laplacian := gray[y-1][x] + gray[y+1][x] + gray[y][x-1] + gray[y][x+1] - 4*gray[y][x]
+			sum += laplacian
+			sumSquares += laplacian * laplacian
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	mean := sum / float64(count)
+	return sumSquares/float64(count) - mean*mean
+}
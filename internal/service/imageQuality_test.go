@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/willtowle1/parkn/internal/common/logger"
+)
+
+func TestCheckQuality(t *testing.T) {
+
+	log, err := logger.NewDefaultLogger("Error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewImageQualityChecker(log, ImageQualityThresholds{
+		MinWidth:      320,
+		MinHeight:     240,
+		MinBrightness: 40,
+		MinSharpness:  60,
+	})
+
+	tests := []struct {
+		name    string
+		content []byte
+		reason  string
+	}{
+		{name: "sharp and bright", content: encodePNG(t, checkerboard(800, 600, 8, 0, 255))},
+		{name: "at the minimum size", content: encodePNG(t, checkerboard(320, 240, 8, 0, 255))},
+		{name: "too narrow", content: encodePNG(t, checkerboard(319, 600, 8, 0, 255)), reason: errImageTooSmall},
+		{name: "too short", content: encodePNG(t, checkerboard(800, 239, 8, 0, 255)), reason: errImageTooSmall},
+		{name: "too dark", content: encodePNG(t, checkerboard(800, 600, 8, 0, 60)), reason: errImageTooDark},
+		{name: "blurry", content: encodePNG(t, checkerboard(800, 600, 8, 128, 128)), reason: errImageBlurry},
+		{name: "format that can't be decoded is let through", content: []byte("\x00\x00\x00\x1cftypheic")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			err := checker.CheckQuality(context.Background(), test.content)
+			if len(test.reason) == 0 {
+				if err != nil {
+					t.Fatalf("CheckQuality() = %v, want nil", err)
+				}
+				return
+			}
+
+			var advice *Advice
+			if !errors.As(err, &advice) {
+				t.Fatalf("CheckQuality() = %v, want advice %q", err, test.reason)
+			}
+			if advice.Reason != test.reason {
+				t.Errorf("CheckQuality() reason = %q, want %q", advice.Reason, test.reason)
+			}
+		})
+	}
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var content bytes.Buffer
+	if err := png.Encode(&content, img); err != nil {
+		t.Fatal(err)
+	}
+	return content.Bytes()
+}
+
+// checkerboard draws squares of size pixels alternating between the dark and light gray levels
+func checkerboard(width, height, size int, dark, light uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			level := dark
+			if (x/size+y/size)%2 == 0 {
+				level = light
+			}
+			img.SetGray(x, y, color.Gray{Y: level})
+		}
+	}
+	return img
+}
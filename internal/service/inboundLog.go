@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// a claim older than this is assumed to belong to a request that died mid-flight and can be taken over.
+	// It is well past Twilio's 15 second webhook timeout.
+	staleClaimAge = time.Minute
+
+	errClaimInbound    = "error while claiming inbound message"
+	errCompleteInbound = "error while completing inbound message"
+	errRecordResult    = "error while recording inbound message result"
+	errInboundMissing  = "inbound message record missing"
+	errFailInbound     = "error while marking inbound message failed"
+
+	msgDuplicateInbound = "duplicate inbound message"
+)
+
+type IInboundDal interface {
+	CreateOne(ctx context.Context, input model.InboundMessage) (string, error)
+	Get(ctx context.Context, filter interface{}) ([]model.InboundMessage, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}) (int64, error)
+}
+
+// InboundLog records every inbound Twilio message by MessageSid so retried deliveries are only processed once
+type InboundLog struct {
+	logger     logger.Logger
+	repository IInboundDal
+}
+
+func NewInboundLog(logger logger.Logger, repository IInboundDal) *InboundLog {
+	return &InboundLog{
+		logger:     logger,
+		repository: repository,
+	}
+}
+
+// Claim records the message as processing. When the MessageSid has been seen before claimed is false
+// and the original record is returned instead.
+func (l *InboundLog) Claim(ctx context.Context, message model.InboundMessage) (model.InboundMessage, bool, error) {
+
+	message.Status = model.InboundStatusProcessing
+	message.ReceivedAt = time.Now()
+
+	_, err := l.repository.CreateOne(ctx, message)
+	if err == nil {
+		return message, true, nil
+	}
+	if !errors.Is(err, errs.ErrDuplicateKey) {
+		return model.InboundMessage{}, false, errs.WrapError(errClaimInbound, err)
+	}
+
+	l.logger.Info(ctx, msgDuplicateInbound, "messageSid", message.MessageSid)
+
+	// take over a claim abandoned by a request that never completed, or one whose processing failed
+	staleFilter := bson.D{
+		{Key: "messageSid", Value: message.MessageSid},
+		{Key: "$or", Value: bson.A{
+			bson.D{
+				{Key: "status", Value: model.InboundStatusProcessing},
+				{Key: "receivedAt", Value: bson.D{
+					{Key: "$lt", Value: primitive.NewDateTimeFromTime(time.Now().Add(-staleClaimAge))},
+				}},
+			},
+			bson.D{{Key: "status", Value: model.InboundStatusFailed}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.InboundStatusProcessing},
+		{Key: "receivedAt", Value: message.ReceivedAt},
+	}}}
+
+	modified, err := l.repository.UpdateOne(ctx, staleFilter, update)
+	if err != nil {
+		return model.InboundMessage{}, false, errs.WrapError(errClaimInbound, err)
+	}
+	if modified > 0 {
+		return message, true, nil
+	}
+
+	existing, err := l.repository.Get(ctx, bson.D{{Key: "messageSid", Value: message.MessageSid}})
+	if err != nil {
+		return model.InboundMessage{}, false, errs.WrapError(errClaimInbound, err)
+	}
+	if len(existing) == 0 {
+		return model.InboundMessage{}, false, errs.WrapError(errClaimInbound, errors.New(errInboundMissing))
+	}
+
+	return existing[0], false, nil
+}
+
+// Complete stores the webhook response so duplicate deliveries can be answered with it
+func (l *InboundLog) Complete(ctx context.Context, messageSid string, responseCode int, responseType, response string) error {
+
+	filter := bson.D{{Key: "messageSid", Value: messageSid}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.InboundStatusCompleted},
+		{Key: "responseCode", Value: responseCode},
+		{Key: "responseType", Value: responseType},
+		{Key: "response", Value: response},
+		{Key: "completedAt", Value: time.Now()},
+	}}}
+
+	_, err := l.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errCompleteInbound, err)
+	}
+	return nil
+}
+
+// Fail marks a claim failed so a retried delivery is processed again, keeping the message and its result for
+// support
+func (l *InboundLog) Fail(ctx context.Context, messageSid string, responseCode int) error {
+
+	filter := bson.D{{Key: "messageSid", Value: messageSid}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.InboundStatusFailed},
+		{Key: "responseCode", Value: responseCode},
+		{Key: "failedAt", Value: time.Now()},
+	}}}
+
+	_, err := l.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errFailInbound, err)
+	}
+	return nil
+}
+
+// RecordResult stores the outcome of processing the message for support
+func (l *InboundLog) RecordResult(ctx context.Context, messageSid, result string) error {
+
+	filter := bson.D{{Key: "messageSid", Value: messageSid}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "result", Value: result}}}}
+
+	_, err := l.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errRecordResult, err)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+)
+
+const (
+	errLookupStreet = "error while looking up street for location"
+
+	msgLocationFound = "found shared location"
+)
+
+var (
+	mapUrlRegex      = regexp.MustCompile(`(?i)https?://(?:maps\.apple\.com|maps\.google\.[a-z.]+|(?:www\.)?google\.[a-z.]+/maps)\S*`)
+	coordinatesRegex = regexp.MustCompile(`(-?\d{1,2}\.\d{3,})\s*,\s*(-?\d{1,3}\.\d{3,})`)
+	linkCoordsRegex  = regexp.MustCompile(`^\s*(-?\d{1,2}(?:\.\d+)?)\s*,\s*(-?\d{1,3}(?:\.\d+)?)\s*$`)
+	googleAtRegex    = regexp.MustCompile(`@(-?\d{1,2}\.\d+),(-?\d{1,3}\.\d+)`)
+	googlePlaceRegex = regexp.MustCompile(`/maps/place/([^/@?]+)`)
+	vCardEscaper     = strings.NewReplacer(`\,`, ",", `\;`, ";", `\:`, ":", `\n`, " ", `\N`, " ")
+
+	// map links carry the coordinates in one of these query parameters depending on the app that shared them
+	coordinateParams = []string{"ll", "q", "query", "sll", "daddr", "center"}
+
+	// names map apps give a pin when there is no address, which are no use in an alert
+	genericPlaceNames = map[string]bool{
+		"DROPPED PIN":      true,
+		"MY LOCATION":      true,
+		"CURRENT LOCATION": true,
+		"MARKED LOCATION":  true,
+		"SHARED LOCATION":  true,
+		"PINNED LOCATION":  true,
+	}
+)
+
+type IGeocoder interface {
+	StreetAt(ctx context.Context, latitude, longitude float64) (string, error)
+}
+
+// Locator finds where the user parked from a shared Apple or Google Maps link, plain coordinates or a
+// location vCard, looking the street up when the share did not include one
+type Locator struct {
+	logger   logger.Logger
+	geocoder IGeocoder
+}
+
+// NewLocator returns a Locator. geocoder may be nil, in which case only streets named in the share are used.
+func NewLocator(logger logger.Logger, geocoder IGeocoder) *Locator {
+	return &Locator{
+		logger:   logger,
+		geocoder: geocoder,
+	}
+}
+
+// Locate returns the location shared in the message text or vCards, or nil when there is none
+func (l *Locator) Locate(ctx context.Context, text string, vCards [][]byte) *model.Location {
+
+	var location model.Location
+	found := false
+	for _, vCard := range vCards {
+		location, found = parseVCard(string(vCard))
+		if found {
+			break
+		}
+	}
+	if !found {
+		location, found = parseLocationText(text)
+	}
+	if !found {
+		return nil
+	}
+
+	return l.withStreet(ctx, location)
+}
+
+// LocateCoordinates returns the location of a WhatsApp location message, or nil when the coordinates are invalid
+func (l *Locator) LocateCoordinates(ctx context.Context, latitude, longitude, address string) *model.Location {
+
+	location, found := toLocation(latitude, longitude)
+	if !found {
+		return nil
+	}
+	location.Street = streetFromAddress(address)
+
+	return l.withStreet(ctx, location)
+}
+
+// withStreet looks the street up when the share did not name one
+func (l *Locator) withStreet(ctx context.Context, location model.Location) *model.Location {
+
+	if len(location.Street) == 0 && l.geocoder != nil {
+		street, err := l.geocoder.StreetAt(ctx, location.Latitude, location.Longitude)
+		if err != nil {
+			l.logger.Error(ctx, errLookupStreet, err)
+		}
+		location.Street = street
+	}
+
+	l.logger.Info(ctx, msgLocationFound, "street", location.Street)
+	return &location
+}
+
+// parseLocationText looks for a map link first and falls back to bare coordinates such as "40.7128, -74.0060"
+func parseLocationText(text string) (model.Location, bool) {
+
+	for _, link := range mapUrlRegex.FindAllString(text, -1) {
+		location, found := parseMapUrl(link)
+		if found {
+			return location, true
+		}
+	}
+
+	match := coordinatesRegex.FindStringSubmatch(text)
+	if match == nil {
+		return model.Location{}, false
+	}
+	return toLocation(match[1], match[2])
+}
+
+// parseMapUrl reads links such as maps.apple.com/?ll=..&address=.., maps.google.com/?q=lat,lng and
+// google.com/maps/place/<address>/@lat,lng,17z
+func parseMapUrl(link string) (model.Location, bool) {
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return model.Location{}, false
+	}
+	query := parsed.Query()
+
+	var location model.Location
+	found := false
+	for _, param := range coordinateParams {
+		match := linkCoordsRegex.FindStringSubmatch(query.Get(param))
+		if match != nil {
+			location, found = toLocation(match[1], match[2])
+			if found {
+				break
+			}
+		}
+	}
+	if !found {
+		match := googleAtRegex.FindStringSubmatch(parsed.Path)
+		if match == nil {
+			return model.Location{}, false
+		}
+		location, found = toLocation(match[1], match[2])
+		if !found {
+			return model.Location{}, false
+		}
+	}
+
+	if address := query.Get("address"); len(address) > 0 {
+		location.Street = streetFromAddress(address)
+	} else if match := googlePlaceRegex.FindStringSubmatch(parsed.Path); match != nil {
+		place, err := url.PathUnescape(strings.ReplaceAll(match[1], "+", " "))
+		if err == nil {
+			location.Street = streetFromAddress(place)
+		}
+	} else if name := query.Get("q"); !linkCoordsRegex.MatchString(name) {
+		location.Street = streetFromAddress(name)
+	}
+
+	return location, true
+}
+
+// parseVCard reads the URL, GEO and ADR properties of a location vCard such as the .loc.vcf files iOS shares
+func parseVCard(content string) (model.Location, bool) {
+
+	// folded lines continue with a leading space or tab
+	unfolded := strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(content)
+
+	var location model.Location
+	found := false
+	street := ""
+	for _, line := range strings.Split(unfolded, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+
+		// drop parameters such as ";type=pref" and group prefixes such as "item1."
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+
+		switch name {
+		case "URL":
+			if urlLocation, urlFound := parseLocationText(vCardEscaper.Replace(value)); urlFound && !found {
+				location, found = urlLocation, true
+			}
+		case "GEO":
+			value = strings.TrimPrefix(strings.ToLower(value), "geo:")
+			latitude, longitude, ok := strings.Cut(strings.ReplaceAll(value, ";", ","), ",")
+			if geoLocation, geoFound := toLocation(latitude, longitude); ok && geoFound && !found {
+				location, found = geoLocation, true
+			}
+		case "ADR":
+			// PO box;extended address;street;city;region;postal code;country
+			parts := strings.Split(value, ";")
+			if len(parts) > 2 {
+				street = streetFromAddress(vCardEscaper.Replace(parts[2]))
+			}
+		}
+	}
+
+	if found && len(street) > 0 {
+		location.Street = street
+	}
+	return location, found
+}
+
+func toLocation(latitude, longitude string) (model.Location, bool) {
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latitude), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return model.Location{}, false
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(longitude), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return model.Location{}, false
+	}
+
+	return model.Location{Latitude: lat, Longitude: lng}, true
+}
+
+// streetFromAddress keeps the first line of an address such as "12 Main St, Boston, MA 02118"
+func streetFromAddress(address string) string {
+
+	street, _, _ := strings.Cut(address, ",")
+	street = strings.TrimSpace(street)
+	if genericPlaceNames[strings.ToUpper(street)] {
+		return ""
+	}
+	return street
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+)
+
+const (
+	errSendingMessage     = "error while sending message"
+	errNoWhatsAppTemplate = "no approved whatsapp template configured"
+	errNoWhatsAppNumber   = "no whatsapp sender number configured"
+
+	msgMessageSent = "successfully sent message"
+)
+
+// Template is an approved WhatsApp content template along with the values of its numbered placeholders
+type Template struct {
+	ContentSid string
+	Variables  map[string]string
+}
+
+type ISuppressionList interface {
+	IsSuppressed(ctx context.Context, phoneNumber string) (bool, error)
+}
+
+// Messenger sends outbound messages through the Twilio REST API on the channel the user wrote in on
+type Messenger struct {
+	logger            logger.Logger
+	twilio            *twilio.RestClient
+	twilioNumber      string
+	whatsAppNumber    string
+	statusCallbackUrl string
+	suppressions      ISuppressionList
+}
+
+// NewMessenger returns a Messenger. Without a statusCallbackUrl Twilio never reports failed deliveries, so they are not retried.
+func NewMessenger(logger logger.Logger, twilio *twilio.RestClient, twilioNumber, whatsAppNumber, statusCallbackUrl string, suppressions ISuppressionList) *Messenger {
+	return &Messenger{
+		logger:            logger,
+		twilio:            twilio,
+		twilioNumber:      twilioNumber,
+		whatsAppNumber:    whatsAppNumber,
+		statusCallbackUrl: statusCallbackUrl,
+		suppressions:      suppressions,
+	}
+}
+
+// Send sends free-form text. On WhatsApp this is only delivered inside the 24 hour session that
+// follows the user's last message, so it is meant for replies.
+func (m *Messenger) Send(ctx context.Context, phoneNumber, channel, body string) error {
+	_, err := m.send(ctx, phoneNumber, channel, body)
+	return err
+}
+
+// SendTemplate sends a proactive message and returns its SID, which Twilio's delivery status callbacks refer to.
+// WhatsApp only allows those outside a session as approved templates, so the template is used there and the body
+// everywhere else.
+func (m *Messenger) SendTemplate(ctx context.Context, phoneNumber, channel, body string, template Template) (string, error) {
+
+	if channel != model.ChannelWhatsApp {
+		return m.send(ctx, phoneNumber, channel, body)
+	}
+	if len(template.ContentSid) == 0 {
+		return "", errs.WrapError(errSendingMessage, errors.New(errNoWhatsAppTemplate))
+	}
+
+	params := &twilioApi.CreateMessageParams{}
+	params.SetContentSid(template.ContentSid)
+	if len(template.Variables) > 0 {
+		variables, err := json.Marshal(template.Variables)
+		if err != nil {
+			return "", errs.WrapError(errSendingMessage, err)
+		}
+		params.SetContentVariables(string(variables))
+	}
+
+	return m.create(ctx, phoneNumber, channel, params)
+}
+
+func (m *Messenger) send(ctx context.Context, phoneNumber, channel, body string) (string, error) {
+
+	params := &twilioApi.CreateMessageParams{}
+	params.SetBody(body)
+
+	return m.create(ctx, phoneNumber, channel, params)
+}
+
+// create sends the message and returns its SID unless the recipient opted out, in which case ErrRecipientSuppressed
+// is returned
+func (m *Messenger) create(ctx context.Context, phoneNumber, channel string, params *twilioApi.CreateMessageParams) (string, error) {
+
+	err := checkSuppressed(ctx, m.suppressions, phoneNumber)
+	if err != nil {
+		return "", errs.WrapError(errSendingMessage, err)
+	}
+
+	from := m.twilioNumber
+	if channel == model.ChannelWhatsApp {
+		if len(m.whatsAppNumber) == 0 {
+			return "", errs.WrapError(errSendingMessage, errors.New(errNoWhatsAppNumber))
+		}
+		from = m.whatsAppNumber
+	}
+
+	params.SetTo(model.JoinAddress(phoneNumber, channel))
+	params.SetFrom(model.JoinAddress(from, channel))
+	if len(m.statusCallbackUrl) > 0 {
+		params.SetStatusCallback(m.statusCallbackUrl)
+	}
+
+	message, err := m.twilio.Api.CreateMessage(params)
+	if err != nil {
+		return "", errs.WrapError(errSendingMessage, err)
+	}
+
+	messageSid := ""
+	if message.Sid != nil {
+		messageSid = *message.Sid
+	}
+
+	m.logger.Debug(ctx, msgMessageSent, "phoneNumber", phoneNumber, "channel", channel, "messageSid", messageSid)
+	return messageSid, nil
+}
+
+// checkSuppressed returns ErrRecipientSuppressed for numbers that opted out. When the list can't be read nothing is
+// sent, since texting someone who opted out is worse than a missed message.
+func checkSuppressed(ctx context.Context, suppressions ISuppressionList, phoneNumber string) error {
+	suppressed, err := suppressions.IsSuppressed(ctx, phoneNumber)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		return ErrRecipientSuppressed
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"math/bits"
+	"sync/atomic"
+	"time"
+
+	vision "cloud.google.com/go/vision/v2/apiv1/visionpb"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// hashBandCount splits the 64 bit perceptual hash into 8 bit bands. Two hashes within a
+	// hamming distance of hashBandCount-1 are guaranteed to share at least one band.
+	hashBandCount       = 8
+	hashBandBits        = 8
+	maxHashBandDistance = hashBandCount - 1
+
+	errOcrCacheLookup = "error while looking up ocr cache"
+	errOcrCacheStore  = "error while storing ocr cache entry"
+	errPerceptualHash = "error while computing perceptual hash"
+	errNoImageContent = "no image content to hash"
+
+	msgOcrCacheHit     = "ocr cache hit"
+	msgOcrCacheNearHit = "ocr cache near duplicate hit"
+	msgOcrCacheMiss    = "ocr cache miss"
+)
+
+type IOcrCacheDal interface {
+	CreateOne(ctx context.Context, input model.OcrCacheEntry) (string, error)
+	Get(ctx context.Context, filter interface{}) ([]model.OcrCacheEntry, error)
+}
+
+type CachedTextExtractor struct {
+	logger          logger.Logger
+	textExtractor   ITextExtractor
+	repository      IOcrCacheDal
+	perceptualHash  bool
+	maxHashDistance int
+
+	hits          atomic.Int64
+	nearDupHits   atomic.Int64
+	misses        atomic.Int64
+	lookupErrors  atomic.Int64
+	storeFailures atomic.Int64
+}
+
+func NewCachedTextExtractor(logger logger.Logger, textExtractor ITextExtractor, repository IOcrCacheDal, perceptualHash bool, maxHashDistance int) *CachedTextExtractor {
+	if maxHashDistance > maxHashBandDistance {
+		maxHashDistance = maxHashBandDistance
+	}
+	if maxHashDistance < 0 {
+		maxHashDistance = 0
+	}
+	return &CachedTextExtractor{
+		logger:          logger,
+		textExtractor:   textExtractor,
+		repository:      repository,
+		perceptualHash:  perceptualHash,
+		maxHashDistance: maxHashDistance,
+	}
+}
+
+// ExtractTextFromImage returns cached text for previously seen images and only calls the
+// wrapped extractor on a miss
+func (c *CachedTextExtractor) ExtractTextFromImage(ctx context.Context, image *vision.Image) (string, error) {
+
+	if image == nil || len(image.Content) == 0 {
+		return c.textExtractor.ExtractTextFromImage(ctx, image)
+	}
+
+	contentHash := hashContent(image.Content)
+
+	entries, err := c.repository.Get(ctx, bson.D{{Key: "contentHash", Value: contentHash}})
+	if err != nil {
+		c.lookupErrors.Add(1)
+		c.logger.Error(ctx, errOcrCacheLookup, err, "contentHash", contentHash)
+	} else if len(entries) > 0 {
+		c.hits.Add(1)
+		c.logger.Debug(ctx, msgOcrCacheHit, "contentHash", contentHash)
+		return entries[0].Text, nil
+	}
+
+	var pHash uint64
+	hasPHash := false
+	if c.perceptualHash {
+		pHash, err = perceptualHash(image.Content)
+		if err != nil {
+			c.logger.Error(ctx, errPerceptualHash, err, "contentHash", contentHash)
+		} else {
+			hasPHash = true
+			if text, found := c.findNearDuplicate(ctx, pHash); found {
+				c.nearDupHits.Add(1)
+				c.logger.Debug(ctx, msgOcrCacheNearHit, "contentHash", contentHash)
+				return text, nil
+			}
+		}
+	}
+
+	c.misses.Add(1)
+	c.logger.Debug(ctx, msgOcrCacheMiss, "contentHash", contentHash)
+
+	text, err := c.textExtractor.ExtractTextFromImage(ctx, image)
+	if err != nil {
+		return "", err
+	}
+
+	entry := model.OcrCacheEntry{
+		ContentHash: contentHash,
+		Text:        text,
+		CreatedAt:   time.Now(),
+	}
+	if hasPHash {
+		entry.PerceptualHash = int64(pHash)
+		entry.HashBands = hashBands(pHash)
+	}
+
+	_, err = c.repository.CreateOne(ctx, entry)
+	if err != nil {
+		c.storeFailures.Add(1)
+		c.logger.Error(ctx, errOcrCacheStore, err, "contentHash", contentHash)
+	}
+
+	return text, nil
+}
+
+// ConvertToVisionImage is not cached and is passed straight through to the wrapped extractor
+func (c *CachedTextExtractor) ConvertToVisionImage(ctx context.Context, b64Str string) (*vision.Image, error) {
+	return c.textExtractor.ConvertToVisionImage(ctx, b64Str)
+}
+
+func (c *CachedTextExtractor) Stats() model.OcrCacheStats {
+	return model.OcrCacheStats{
+		Hits:          c.hits.Load(),
+		NearDupHits:   c.nearDupHits.Load(),
+		Misses:        c.misses.Load(),
+		LookupErrors:  c.lookupErrors.Load(),
+		StoreFailures: c.storeFailures.Load(),
+	}
+}
+
+func (c *CachedTextExtractor) findNearDuplicate(ctx context.Context, pHash uint64) (string, bool) {
+
+	filter := bson.D{
+		{Key: "hashBands", Value: bson.D{
+			{Key: "$in", Value: hashBands(pHash)},
+		}},
+	}
+
+	candidates, err := c.repository.Get(ctx, filter)
+	if err != nil {
+		c.lookupErrors.Add(1)
+		c.logger.Error(ctx, errOcrCacheLookup, err)
+		return "", false
+	}
+
+	bestDistance := c.maxHashDistance + 1
+	bestText := ""
+	for _, candidate := range candidates {
+		distance := bits.OnesCount64(uint64(candidate.PerceptualHash) ^ pHash)
+		if distance < bestDistance {
+			bestDistance = distance
+			bestText = candidate.Text
+		}
+	}
+
+	return bestText, bestDistance <= c.maxHashDistance
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// perceptualHash computes a 64 bit difference hash over a 9x8 grayscale thumbnail of the image
+func perceptualHash(content []byte) (uint64, error) {
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return 0, err
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return 0, errors.New(errNoImageContent)
+	}
+
+	const width, height = 9, 8
+	var thumbnail [height][width]uint64
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+			thumbnail[y][x] = averageLuminance(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if thumbnail[y][x] < thumbnail[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash, nil
+}
+
+func averageLuminance(img image.Image, x0, y0, x1, y1 int) uint64 {
+	var total, count uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			total += (299*uint64(r) + 587*uint64(g) + 114*uint64(b)) / 1000
+			count++
+		}
+	}
+	return total / count
+}
+
+// hashBands tags each 8 bit slice of the hash with its position so bands only match their own slot
+func hashBands(hash uint64) []int {
+	bands := make([]int, hashBandCount)
+	for i := 0; i < hashBandCount; i++ {
+		band := (hash >> (i * hashBandBits)) & (1<<hashBandBits - 1)
+		bands[i] = i<<hashBandBits | int(band)
+	}
+	return bands
+}
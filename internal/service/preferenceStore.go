@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	errGetPreferences = "error while getting preferences"
+	errSetPreferences = "error while setting preferences"
+
+	msgPreferenceSet = "preference updated"
+)
+
+type IPreferenceDal interface {
+	Get(ctx context.Context, filter interface{}) ([]model.Preferences, error)
+	UpsertFields(ctx context.Context, filter interface{}, update interface{}) error
+}
+
+// PreferenceStore keeps the settings each user changes by keyword, such as how alerts are delivered
+type PreferenceStore struct {
+	logger           logger.Logger
+	repository       IPreferenceDal
+	defaultLeadTimes []model.LeadTime
+	defaultQuiet     model.QuietHours
+}
+
+func NewPreferenceStore(logger logger.Logger, repository IPreferenceDal, defaultLeadTimes []model.LeadTime, defaultQuiet model.QuietHours) *PreferenceStore {
+	return &PreferenceStore{
+		logger:           logger,
+		repository:       repository,
+		defaultLeadTimes: defaultLeadTimes,
+		defaultQuiet:     defaultQuiet,
+	}
+}
+
+// Get returns the preferences for a phone number with defaults filled in for anything never set. Language
+// is left empty until the user picks one or it is detected, so callers can tell the two apart.
+func (p *PreferenceStore) Get(ctx context.Context, phoneNumber string) (model.Preferences, error) {
+
+	preferences := model.Preferences{PhoneNumber: phoneNumber}
+
+	found, err := p.repository.Get(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		return preferences, errs.WrapError(errGetPreferences, err)
+	}
+	if len(found) > 0 {
+		preferences = found[0]
+	}
+	return p.withDefaults(preferences), nil
+}
+
+// Defaults returns the preferences of a phone number that never changed any, used when they can't be read
+func (p *PreferenceStore) Defaults(phoneNumber string) model.Preferences {
+	return p.withDefaults(model.Preferences{PhoneNumber: phoneNumber})
+}
+
+func (p *PreferenceStore) withDefaults(preferences model.Preferences) model.Preferences {
+	if len(preferences.Delivery) == 0 {
+		preferences.Delivery = model.DeliveryText
+	}
+	if len(preferences.LeadTimes) == 0 {
+		preferences.LeadTimes = p.defaultLeadTimes
+	}
+	if preferences.QuietHours == nil {
+		quiet := p.defaultQuiet
+		preferences.QuietHours = &quiet
+	}
+	return preferences
+}
+
+// SetDelivery switches alerts for a phone number between text messages and voice calls
+func (p *PreferenceStore) SetDelivery(ctx context.Context, phoneNumber, delivery string) error {
+	return p.set(ctx, phoneNumber, "delivery", delivery)
+}
+
+// SetLanguage sets the language of every message sent to a phone number
+func (p *PreferenceStore) SetLanguage(ctx context.Context, phoneNumber, language string) error {
+	return p.set(ctx, phoneNumber, "language", language)
+}
+
+// SetLeadTimes sets when reminders go out before each sweep. No lead times goes back to the defaults.
+func (p *PreferenceStore) SetLeadTimes(ctx context.Context, phoneNumber string, leadTimes []model.LeadTime) error {
+	return p.set(ctx, phoneNumber, "leadTimes", leadTimes)
+}
+
+// SetQuietHours sets when alerts to a phone number are held. Nil goes back to the defaults.
+func (p *PreferenceStore) SetQuietHours(ctx context.Context, phoneNumber string, quiet *model.QuietHours) error {
+	return p.set(ctx, phoneNumber, "quietHours", quiet)
+}
+
+func (p *PreferenceStore) set(ctx context.Context, phoneNumber, key string, value interface{}) error {
+
+	filter := bson.D{{Key: "phoneNumber", Value: phoneNumber}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: key, Value: value},
+		{Key: "updatedAt", Value: time.Now()},
+	}}}
+
+	err := p.repository.UpsertFields(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errSetPreferences, err)
+	}
+
+	p.logger.Info(ctx, msgPreferenceSet, "phoneNumber", phoneNumber, key, fmt.Sprint(value))
+	return nil
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RateAllowed   = "allowed"
+	RateThrottled = "throttled"
+	RateBusy      = "busy"
+	RateBlocked   = "blocked"
+
+	errCheckRate      = "error while checking rate limits"
+	errCountRate      = "error while counting towards rate limit"
+	errBlockSender    = "error while blocking sender"
+	errListBlocked    = "error while listing blocked senders"
+	errUnblockSender  = "error while unblocking sender"
+	errSenderNotFound = "sender is not blocked"
+
+	msgSenderThrottled = "sender over rate limit"
+	msgGlobalThrottled = "global media rate limit reached"
+	msgSenderBlocked   = "sender blocked for repeatedly going over rate limits"
+	msgSenderUnblocked = "sender unblocked"
+
+	reasonMessageLimit = "over message limit"
+	reasonMediaLimit   = "over media limit"
+
+	counterMessages = "messages"
+	counterMedia    = "media"
+	counterStrikes  = "strikes"
+	counterNotices  = "notices"
+	globalSender    = "global"
+)
+
+var (
+	ErrSenderNotBlocked = errors.New(errSenderNotFound)
+)
+
+type IRateCounterDal interface {
+	FindOneAndUpsert(ctx context.Context, filter interface{}, update interface{}) (model.RateCounter, error)
+}
+
+type IBlockedSenderDal interface {
+	Get(ctx context.Context, filter interface{}) ([]model.BlockedSender, error)
+	UpsertFields(ctx context.Context, filter interface{}, update interface{}) error
+	DeleteOne(ctx context.Context, filter interface{}) (int64, error)
+}
+
+// RateLimitPolicy bounds how much one sender, and everyone together, can make us do. Limits of zero are not enforced.
+type RateLimitPolicy struct {
+	Window         time.Duration
+	SenderMessages int
+	SenderMedia    int
+	GlobalMedia    int
+	// a sender throttled StrikesToBlock times within StrikeWindow is blocked for BlockDuration, or until
+	// an admin unblocks them when BlockDuration is zero
+	StrikesToBlock int
+	StrikeWindow   time.Duration
+	BlockDuration  time.Duration
+}
+
+// RateVerdict is the outcome of checking an inbound message against the rate limits
+type RateVerdict struct {
+	Decision string
+	// Notify is set for the first throttled message in a window so the sender is told once rather than every time
+	Notify bool
+}
+
+// RateLimiter counts inbound messages and photos in Mongo so the limits hold across replicas, and blocks
+// senders who keep going over them
+type RateLimiter struct {
+	logger   logger.Logger
+	counters IRateCounterDal
+	blocked  IBlockedSenderDal
+	policy   RateLimitPolicy
+}
+
+func NewRateLimiter(logger logger.Logger, counters IRateCounterDal, blocked IBlockedSenderDal, policy RateLimitPolicy) *RateLimiter {
+	return &RateLimiter{
+		logger:   logger,
+		counters: counters,
+		blocked:  blocked,
+		policy:   policy,
+	}
+}
+
+// Check counts a message with numMedia photos from phoneNumber and decides whether it should be processed
+func (r *RateLimiter) Check(ctx context.Context, phoneNumber string, numMedia int) (RateVerdict, error) {
+
+	blocked, err := r.isBlocked(ctx, phoneNumber)
+	if err != nil {
+		return RateVerdict{}, errs.WrapError(errCheckRate, err)
+	}
+	if blocked {
+		return RateVerdict{Decision: RateBlocked}, nil
+	}
+
+	messages, err := r.count(ctx, phoneNumber, counterMessages, r.policy.Window, 1)
+	if err != nil {
+		return RateVerdict{}, errs.WrapError(errCheckRate, err)
+	}
+	if overLimit(messages, r.policy.SenderMessages) {
+		return r.throttle(ctx, phoneNumber, reasonMessageLimit)
+	}
+
+	if numMedia == 0 {
+		return RateVerdict{Decision: RateAllowed}, nil
+	}
+
+	media, err := r.count(ctx, phoneNumber, counterMedia, r.policy.Window, numMedia)
+	if err != nil {
+		return RateVerdict{}, errs.WrapError(errCheckRate, err)
+	}
+	if overLimit(media, r.policy.SenderMedia) {
+		return r.throttle(ctx, phoneNumber, reasonMediaLimit)
+	}
+
+	// a busy service is nobody's fault, so going over the global limit is not a strike
+	global, err := r.count(ctx, globalSender, counterMedia, r.policy.Window, numMedia)
+	if err != nil {
+		return RateVerdict{}, errs.WrapError(errCheckRate, err)
+	}
+	if overLimit(global, r.policy.GlobalMedia) {
+		r.logger.Info(ctx, msgGlobalThrottled, "count", strconv.Itoa(global))
+		return RateVerdict{Decision: RateBusy, Notify: true}, nil
+	}
+
+	return RateVerdict{Decision: RateAllowed}, nil
+}
+
+// ListBlocked returns the senders blocked right now
+func (r *RateLimiter) ListBlocked(ctx context.Context) ([]model.BlockedSender, error) {
+	blocked, err := r.blocked.Get(ctx, activeBlockFilter(bson.E{}))
+	if err != nil {
+		return nil, errs.WrapError(errListBlocked, err)
+	}
+	return blocked, nil
+}
+
+// Unblock lifts a block. It returns ErrSenderNotBlocked when the number is not blocked.
+func (r *RateLimiter) Unblock(ctx context.Context, phoneNumber string) error {
+
+	deleted, err := r.blocked.DeleteOne(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		return errs.WrapError(errUnblockSender, err)
+	}
+	if deleted == 0 {
+		return ErrSenderNotBlocked
+	}
+
+	r.logger.Info(ctx, msgSenderUnblocked, "phoneNumber", phoneNumber)
+	return nil
+}
+
+// throttle records a strike against the sender, blocking them once they have too many
+func (r *RateLimiter) throttle(ctx context.Context, phoneNumber, reason string) (RateVerdict, error) {
+
+	r.logger.Info(ctx, msgSenderThrottled, "phoneNumber", phoneNumber, "reason", reason)
+
+	strikes, err := r.count(ctx, phoneNumber, counterStrikes, r.policy.StrikeWindow, 1)
+	if err != nil {
+		return RateVerdict{}, errs.WrapError(errCheckRate, err)
+	}
+	if r.policy.StrikesToBlock > 0 && strikes >= r.policy.StrikesToBlock {
+		err = r.block(ctx, phoneNumber, reason, strikes)
+		if err != nil {
+			return RateVerdict{}, err
+		}
+		return RateVerdict{Decision: RateBlocked}, nil
+	}
+
+	notices, err := r.count(ctx, phoneNumber, counterNotices, r.policy.Window, 1)
+	if err != nil {
+		return RateVerdict{}, errs.WrapError(errCheckRate, err)
+	}
+
+	return RateVerdict{Decision: RateThrottled, Notify: notices == 1}, nil
+}
+
+func (r *RateLimiter) block(ctx context.Context, phoneNumber, reason string, strikes int) error {
+
+	now := time.Now()
+	fields := bson.D{
+		{Key: "reason", Value: reason},
+		{Key: "strikes", Value: strikes},
+		{Key: "blockedAt", Value: now},
+	}
+
+	var update bson.D
+	if r.policy.BlockDuration > 0 {
+		fields = append(fields, bson.E{Key: "expiresAt", Value: now.Add(r.policy.BlockDuration)})
+		update = bson.D{{Key: "$set", Value: fields}}
+	} else {
+		update = bson.D{
+			{Key: "$set", Value: fields},
+			{Key: "$unset", Value: bson.D{{Key: "expiresAt", Value: ""}}},
+		}
+	}
+
+	err := r.blocked.UpsertFields(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}}, update)
+	if err != nil {
+		return errs.WrapError(errBlockSender, err)
+	}
+
+	r.logger.Info(ctx, msgSenderBlocked, "phoneNumber", phoneNumber, "reason", reason, "strikes", strconv.Itoa(strikes))
+	return nil
+}
+
+func (r *RateLimiter) isBlocked(ctx context.Context, phoneNumber string) (bool, error) {
+	blocked, err := r.blocked.Get(ctx, activeBlockFilter(bson.E{Key: "phoneNumber", Value: phoneNumber}))
+	if err != nil {
+		return false, err
+	}
+	return len(blocked) > 0, nil
+}
+
+// count adds amount to the sender's counter for the current window and returns the new total
+func (r *RateLimiter) count(ctx context.Context, sender, counter string, window time.Duration, amount int) (int, error) {
+
+	key, windowStart := windowKey(counter, sender, time.Now(), window)
+	filter := bson.D{{Key: "key", Value: key}}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "count", Value: amount}}},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "windowStart", Value: windowStart},
+			{Key: "expiresAt", Value: windowStart.Add(window)},
+		}},
+	}
+
+	counted, err := r.counters.FindOneAndUpsert(ctx, filter, update)
+	if errors.Is(err, errs.ErrDuplicateKey) {
+		// another replica created the counter first, so it now exists to be incremented
+		counted, err = r.counters.FindOneAndUpsert(ctx, filter, update)
+	}
+	if err != nil {
+		return 0, errs.WrapError(errCountRate, err)
+	}
+
+	return counted.Count, nil
+}
+
+// windowKey names the counter for a sender in the fixed window now falls in, and returns when that window started.
+// Windows are aligned to the epoch so every replica agrees on them.
+func windowKey(counter, sender string, now time.Time, window time.Duration) (string, time.Time) {
+	windowStart := now.Truncate(window)
+	return fmt.Sprintf("%s:%s:%d", counter, sender, windowStart.Unix()), windowStart
+}
+
+// activeBlockFilter matches blocks that have not expired yet, since the TTL monitor only removes them about once a minute
+func activeBlockFilter(match bson.E) bson.D {
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "expiresAt", Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.D{{Key: "expiresAt", Value: bson.D{{Key: "$gt", Value: primitive.NewDateTimeFromTime(time.Now())}}}},
+	}}}
+	if len(match.Key) > 0 {
+		filter = append(filter, match)
+	}
+	return filter
+}
+
+func overLimit(count, limit int) bool {
+	return limit > 0 && count > limit
+}
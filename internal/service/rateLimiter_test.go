@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowKey(t *testing.T) {
+
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	window := time.Hour
+	key, windowStart := windowKey(counterMessages, "+15550001111", start, window)
+
+	if want := "messages:+15550001111:1767607200"; key != want {
+		t.Errorf("windowKey = %q, want %q", key, want)
+	}
+	if !windowStart.Equal(start) {
+		t.Errorf("windowStart = %v, want %v", windowStart, start)
+	}
+
+	tests := []struct {
+		name    string
+		counter string
+		sender  string
+		now     time.Time
+		same    bool
+	}{
+		{name: "later in the window", counter: counterMessages, sender: "+15550001111", now: start.Add(59*time.Minute + 59*time.Second), same: true},
+		{name: "next window", counter: counterMessages, sender: "+15550001111", now: start.Add(window), same: false},
+		{name: "previous window", counter: counterMessages, sender: "+15550001111", now: start.Add(-time.Second), same: false},
+		{name: "other counter", counter: counterMedia, sender: "+15550001111", now: start, same: false},
+		{name: "other sender", counter: counterMessages, sender: "+15550002222", now: start, same: false},
+		{name: "same instant in another zone", counter: counterMessages, sender: "+15550001111", now: start.In(time.FixedZone("EST", -5*60*60)), same: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _ := windowKey(test.counter, test.sender, test.now, window)
+			if (got == key) != test.same {
+				t.Errorf("windowKey(%v) = %q, same as %q should be %v", test.now, got, key, test.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/logger"
+	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	errEnqueueRetry    = "error while queueing failed alert"
+	errClaimQueued     = "error while claiming queued alert"
+	errRemoveRetry     = "error while removing queued alert"
+	errRescheduleRetry = "error while rescheduling queued alert"
+	errHoldRetry       = "error while holding queued alert"
+	errDeadLetter      = "error while dead-lettering queued alert"
+	errListDead        = "error while listing dead-lettered alerts"
+	errRedrive         = "error while re-driving dead-lettered alert"
+	errRetryNotFound   = "no dead-lettered alert with that id"
+	errRetryPastSweep  = "the sweep the dead-lettered alert was for has already started"
+
+	msgRetryQueued   = "failed alert queued for retry"
+	msgRetryDead     = "queued alert dead-lettered"
+	msgRetryRedriven = "dead-lettered alert re-driven"
+
+	// retryClaimLease is how long a claimed retry is held, so a run that dies mid-send doesn't strand it
+	retryClaimLease = 5 * time.Minute
+)
+
+var (
+	ErrRetryNotFound  = errors.New(errRetryNotFound)
+	ErrRetryPastSweep = errors.New(errRetryPastSweep)
+)
+
+type IAlertRetryDal interface {
+	CreateOne(ctx context.Context, input model.AlertRetry) (string, error)
+	Get(ctx context.Context, filter interface{}) ([]model.AlertRetry, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}) (int64, error)
+	DeleteOne(ctx context.Context, filter interface{}) (int64, error)
+	FindOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, sort interface{}) (model.AlertRetry, bool, error)
+}
+
+// RetryPolicy is how failed alerts are retried: after BaseDelay, doubling each time up to MaxDelay, until
+// MaxAttempts sends have failed
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// RetryQueue keeps reminders that failed to send in Mongo so they are retried with backoff across restarts, and
+// dead-letters the ones that keep failing for an admin to look at
+type RetryQueue struct {
+	logger     logger.Logger
+	repository IAlertRetryDal
+	policy     RetryPolicy
+}
+
+func NewRetryQueue(logger logger.Logger, repository IAlertRetryDal, policy RetryPolicy) *RetryQueue {
+	return &RetryQueue{
+		logger:     logger,
+		repository: repository,
+		policy:     policy,
+	}
+}
+
+// Enqueue queues the reminders for a parkn after its first send failed with cause. With only one attempt allowed
+// it goes straight to the dead letters.
+func (q *RetryQueue) Enqueue(ctx context.Context, parkn model.Parkn, leadTimes []string, cause error) error {
+
+	now := time.Now()
+	retry := model.AlertRetry{
+		PhoneNumber:   parkn.PhoneNumber,
+		Parkn:         parkn,
+		LeadTimes:     leadTimes,
+		Status:        model.RetryStatusQueued,
+		Attempts:      1,
+		LastError:     cause.Error(),
+		NextAttemptAt: now.Add(q.backoff(1)),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if retry.Attempts >= q.policy.MaxAttempts {
+		retry.Status = model.RetryStatusDead
+		retry.DeadAt = now
+	}
+
+	_, err := q.repository.CreateOne(ctx, retry)
+	if err != nil {
+		return errs.WrapError(errEnqueueRetry, err)
+	}
+
+	if retry.Status == model.RetryStatusDead {
+		q.logger.Info(ctx, msgRetryDead, "phoneNumber", parkn.PhoneNumber, "attempts", strconv.Itoa(retry.Attempts), "lastError", retry.LastError)
+		return nil
+	}
+	q.logger.Info(ctx, msgRetryQueued, "phoneNumber", parkn.PhoneNumber, "nextAttemptAt", retry.NextAttemptAt.Format(time.RFC3339))
+	return nil
+}
+
+// ClaimDue takes the queued retry that has waited longest past its next attempt, holding it for retryClaimLease so
+// another run doesn't send it too. It reports false when none are due.
+func (q *RetryQueue) ClaimDue(ctx context.Context, now time.Time) (model.AlertRetry, bool, error) {
+
+	filter := bson.D{
+		{Key: "status", Value: model.RetryStatusQueued},
+		{Key: "nextAttemptAt", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "nextAttemptAt", Value: now.Add(retryClaimLease)},
+	}}}
+	sort := bson.D{{Key: "nextAttemptAt", Value: 1}}
+
+	retry, found, err := q.repository.FindOneAndUpdate(ctx, filter, update, sort)
+	if err != nil {
+		return model.AlertRetry{}, false, errs.WrapError(errClaimQueued, err)
+	}
+	return retry, found, nil
+}
+
+// Remove takes a retry off the queue once it was sent, or is no longer wanted
+func (q *RetryQueue) Remove(ctx context.Context, id primitive.ObjectID) error {
+	_, err := q.repository.DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return errs.WrapError(errRemoveRetry, err)
+	}
+	return nil
+}
+
+// Failed records another failed attempt, scheduling the next one or dead-lettering the retry once MaxAttempts have
+// failed. It reports whether the retry was dead-lettered.
+func (q *RetryQueue) Failed(ctx context.Context, retry model.AlertRetry, cause error) (bool, error) {
+
+	attempts := retry.Attempts + 1
+	if attempts >= q.policy.MaxAttempts {
+		return true, q.deadLetter(ctx, retry.ID, attempts, cause)
+	}
+
+	now := time.Now()
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "attempts", Value: attempts},
+		{Key: "lastError", Value: cause.Error()},
+		{Key: "nextAttemptAt", Value: now.Add(q.backoff(attempts))},
+		{Key: "updatedAt", Value: now},
+	}}}
+
+	_, err := q.repository.UpdateOne(ctx, bson.D{{Key: "_id", Value: retry.ID}}, update)
+	if err != nil {
+		return false, errs.WrapError(errRescheduleRetry, err)
+	}
+	return false, nil
+}
+
+// Hold moves a claimed retry's next attempt to at without counting one, used when it came due in the user's quiet hours
+func (q *RetryQueue) Hold(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "nextAttemptAt", Value: at},
+		{Key: "updatedAt", Value: time.Now()},
+	}}}
+
+	_, err := q.repository.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	if err != nil {
+		return errs.WrapError(errHoldRetry, err)
+	}
+	return nil
+}
+
+// DeadLetter gives up on a retry without another attempt, such as one for a sweep that has already started
+func (q *RetryQueue) DeadLetter(ctx context.Context, retry model.AlertRetry, cause error) error {
+	return q.deadLetter(ctx, retry.ID, retry.Attempts, cause)
+}
+
+// DeadLetters lists every dead-lettered retry
+func (q *RetryQueue) DeadLetters(ctx context.Context) ([]model.AlertRetry, error) {
+
+	dead, err := q.repository.Get(ctx, bson.D{{Key: "status", Value: model.RetryStatusDead}})
+	if err != nil {
+		return nil, errs.WrapError(errListDead, err)
+	}
+	if dead == nil {
+		dead = make([]model.AlertRetry, 0)
+	}
+	return dead, nil
+}
+
+// Redrive puts a dead-lettered retry back on the queue with its attempts reset, to be tried on the next run. One
+// whose sweep has started would only be dead-lettered again, so it is refused with ErrRetryPastSweep; the parkn's
+// reminders for its next sweep go out on their own.
+func (q *RetryQueue) Redrive(ctx context.Context, id string) error {
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrRetryNotFound
+	}
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "_id", Value: objectID},
+		{Key: "status", Value: model.RetryStatusDead},
+		{Key: "parkn.moveByDate", Value: bson.D{{Key: "$gt", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: model.RetryStatusQueued},
+			{Key: "attempts", Value: 0},
+			{Key: "nextAttemptAt", Value: now},
+			{Key: "updatedAt", Value: now},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "deadAt", Value: ""}}},
+	}
+
+	modified, err := q.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errRedrive, err)
+	}
+	if modified == 0 {
+		return q.notRedriven(ctx, objectID)
+	}
+
+	q.logger.Info(ctx, msgRetryRedriven, "id", id)
+	return nil
+}
+
+// notRedriven tells apart a dead-lettered retry that doesn't exist from one whose sweep has started
+func (q *RetryQueue) notRedriven(ctx context.Context, id primitive.ObjectID) error {
+
+	dead, err := q.repository.Get(ctx, bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: model.RetryStatusDead},
+	})
+	if err != nil {
+		return errs.WrapError(errRedrive, err)
+	}
+	if len(dead) == 0 {
+		return ErrRetryNotFound
+	}
+	return ErrRetryPastSweep
+}
+
+func (q *RetryQueue) deadLetter(ctx context.Context, id primitive.ObjectID, attempts int, cause error) error {
+
+	now := time.Now()
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: model.RetryStatusDead},
+		{Key: "attempts", Value: attempts},
+		{Key: "lastError", Value: cause.Error()},
+		{Key: "deadAt", Value: now},
+		{Key: "updatedAt", Value: now},
+	}}}
+
+	_, err := q.repository.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	if err != nil {
+		return errs.WrapError(errDeadLetter, err)
+	}
+
+	q.logger.Info(ctx, msgRetryDead, "id", id.Hex(), "attempts", strconv.Itoa(attempts), "lastError", cause.Error())
+	return nil
+}
+
+// backoff is how long to wait after the given number of failed attempts: BaseDelay doubled for each one before it,
+// capped at MaxDelay, then jittered to between half and all of that so retries from one outage spread out
+func (q *RetryQueue) backoff(attempts int) time.Duration {
+
+	delay := q.policy.BaseDelay
+	for i := 1; i < attempts && delay < q.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, q.policy.MaxDelay)
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryQueueBackoff(t *testing.T) {
+
+	queue := &RetryQueue{policy: RetryPolicy{
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+	}}
+
+	tests := []struct {
+		attempts int
+		full     time.Duration
+	}{
+		{attempts: 1, full: 30 * time.Second},
+		{attempts: 2, full: time.Minute},
+		{attempts: 3, full: 2 * time.Minute},
+		{attempts: 5, full: 8 * time.Minute},
+		{attempts: 7, full: 32 * time.Minute},
+		{attempts: 8, full: time.Hour},
+		{attempts: 100, full: time.Hour},
+	}
+
+	for _, test := range tests {
+		// jitter is random, so each delay is checked many times to be between half and all of the full delay
+		for i := 0; i < 100; i++ {
+			delay := queue.backoff(test.attempts)
+			if delay < test.full/2 || delay > test.full {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", test.attempts, delay, test.full/2, test.full)
+			}
+		}
+	}
+}
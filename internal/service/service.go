@@ -1,25 +1,73 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	vision "cloud.google.com/go/vision/v2/apiv1/visionpb"
 	"github.com/willtowle1/parkn/internal/common/errs"
 	"github.com/willtowle1/parkn/internal/common/logger"
 	"github.com/willtowle1/parkn/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
-	errCreatingParkn = "failed to create parkn"
+	errCreatingParkn    = "failed to create parkn"
+	errListingParkns    = "failed to list parkns"
+	errCancelingParkn   = "failed to cancel parkn"
+	errInvalidIndex     = "no alert with that number"
+	errConfirmingParkn  = "failed to confirm parkn"
+	errNothingToConfirm = "no reading waiting for confirmation"
+	errCorrectingParkn  = "failed to correct parkn"
+	errNothingToCorrect = "no saved alert to correct"
+	errStoreCorrection  = "error while storing correction"
+	errFetchVCard       = "error while fetching vcard"
+	errSavingLocation   = "failed to save location"
+	errNoLocation       = "no location found in message"
+	errNothingToLocate  = "no reading or alert to attach location to"
+	errCarMoved         = "car moved away from the alert's location"
+	errNamingParkn      = "failed to name parkn"
+	errNothingToName    = "no saved alert to name"
+	errAcknowledgeParkn = "failed to acknowledge parkn"
+	errRetireParkns     = "failed to stop alerts for where the car was parked before"
+	errNoMedia          = "no media to read"
 
-	msgCreateParknSuccess = "successfully created parkn alert"
+	msgCreateParknSuccess  = "successfully created parkn alert"
+	msgCancelParknSuccess  = "successfully canceled parkn alerts"
+	msgPendingParkn        = "parkn reading waiting for confirmation"
+	msgCorrectParknSuccess = "successfully corrected parkn"
+	msgNameParknSuccess    = "successfully named parkn"
+	msgParknAcknowledged   = "car moved, no more reminders for this sweep"
+	msgCarMoved            = "car moved, stopped repeating alert"
+)
+
+var (
+	ErrInvalidIndex     = errors.New(errInvalidIndex)
+	ErrNothingToConfirm = errors.New(errNothingToConfirm)
+	ErrNothingToCorrect = errors.New(errNothingToCorrect)
+	ErrNoLocation       = errors.New(errNoLocation)
+	ErrNothingToLocate  = errors.New(errNothingToLocate)
+	ErrNothingToName    = errors.New(errNothingToName)
+	ErrCarMoved         = errors.New(errCarMoved)
+	ErrNoMedia          = errors.New(errNoMedia)
 )
 
 type IDal interface {
 	CreateOne(ctx context.Context, input model.Parkn) (string, error)
 	Get(ctx context.Context, filter interface{}) ([]model.Parkn, error)
 	DeleteOne(ctx context.Context, filter interface{}) (int64, error)
+	DeleteMany(ctx context.Context, filter interface{}) (int64, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}) (int64, error)
+}
+
+type ICorrectionDal interface {
+	CreateOne(ctx context.Context, input model.Correction) (string, error)
 }
 
 type ITextExtractor interface {
@@ -28,11 +76,52 @@ type ITextExtractor interface {
 }
 
 type IDateSniper interface {
-	SnipeDate(ctx context.Context, str string) (time.Time, error)
+	SnipeDate(ctx context.Context, str string) (model.Schedule, time.Time, error)
+	MatchedPhrase(str string) string
 }
 
 type IClient interface {
-	FetchMedia(ctx context.Context, mediaUrl string) (*vision.Image, error)
+	FetchMedia(ctx context.Context, mediaUrl string) (*Media, error)
+	FetchFile(ctx context.Context, mediaUrl string) ([]byte, string, error)
+}
+
+type ILocator interface {
+	Locate(ctx context.Context, text string, vCards [][]byte) *model.Location
+	LocateCoordinates(ctx context.Context, latitude, longitude, address string) *model.Location
+}
+
+type IImageQualityChecker interface {
+	CheckQuality(ctx context.Context, content []byte) error
+}
+
+type IPendingDal interface {
+	UpsertOne(ctx context.Context, filter interface{}, input model.PendingParkn) error
+	FindOneAndDelete(ctx context.Context, filter interface{}) (model.PendingParkn, bool, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}) (int64, error)
+}
+
+type IArchiver interface {
+	Archive(ctx context.Context, submission model.SubmissionArchive, media [][]byte) (string, error)
+	LinkParkn(ctx context.Context, archiveID, parknID string) error
+	GetByParknID(ctx context.Context, parknID string) (model.SubmissionArchive, error)
+}
+
+// Reading is a schedule read from a submission that is waiting for the user to confirm it
+type Reading struct {
+	Schedule model.Schedule
+	NextOn   time.Time
+	Photo    int
+	Street   string
+}
+
+// SharedLocation is where a message may carry the car's location: map links or coordinates in its
+// text, location vCard attachments, or the coordinates of a WhatsApp location message
+type SharedLocation struct {
+	Text      string
+	VCardUrls []string
+	Latitude  string
+	Longitude string
+	Address   string
 }
 
 type ParknService struct {
@@ -41,56 +130,524 @@ type ParknService struct {
 	sniper        IDateSniper
 	repository    IDal
 	httpClient    IClient
+	archiver      IArchiver
+	quality       IImageQualityChecker
+	pending       IPendingDal
+	pendingTTL    time.Duration
+	corrections   ICorrectionDal
+	locator       ILocator
 }
 
-func NewParknService(logger logger.Logger, textExtractor ITextExtractor, sniper IDateSniper, repository IDal, httpClient IClient) *ParknService {
+func NewParknService(logger logger.Logger, textExtractor ITextExtractor, sniper IDateSniper, repository IDal, httpClient IClient, archiver IArchiver, quality IImageQualityChecker, pending IPendingDal, pendingTTL time.Duration, corrections ICorrectionDal, locator ILocator) *ParknService {
 	return &ParknService{
 		logger:        logger,
 		textExtractor: textExtractor,
 		sniper:        sniper,
 		repository:    repository,
 		httpClient:    httpClient,
+		archiver:      archiver,
+		quality:       quality,
+		pending:       pending,
+		pendingTTL:    pendingTTL,
+		corrections:   corrections,
+		locator:       locator,
 	}
 }
 
-// CreateParkn creates a parkn alert and returns the endDate
-func (s *ParknService) CreateParkn(ctx context.Context, phoneNumber, mediaUrl string) (string, error) {
+// CreateParkn reads a schedule from the attached photos and holds it for the user to confirm. Each
+// photo is tried on its own first and, when none of them holds a full schedule, their text is
+// combined and the reading's photo is zero.
+func (s *ParknService) CreateParkn(ctx context.Context, phoneNumber, channel string, mediaUrls []string, shared SharedLocation) (Reading, error) {
 
-	image, err := s.httpClient.FetchMedia(ctx, mediaUrl)
-	if err != nil {
-		s.logger.Error(ctx, errCreatingParkn, err)
-		return "", errs.WrapError(errCreatingParkn, err)
+	if len(mediaUrls) == 0 {
+		return Reading{}, ErrNoMedia
+	}
+
+	location := s.locate(ctx, shared)
+
+	submission := model.SubmissionArchive{
+		PhoneNumber: phoneNumber,
+		Media:       make([]model.ArchivedMedia, len(mediaUrls)),
 	}
+	content := make([][]byte, len(mediaUrls))
+	texts := make([]string, 0, len(mediaUrls))
+
+	var firstErr error
+	for i, mediaUrl := range mediaUrls {
+
+		submission.Media[i].MediaUrl = mediaUrl
+
+		text, err := s.readMedia(ctx, mediaUrl, &submission.Media[i], &content[i])
+		if err != nil {
+			submission.Media[i].Error = err.Error()
+			firstErr = cmp.Or(firstErr, err)
+			continue
+		}
+		texts = append(texts, text)
 
-	extractedText, err := s.textExtractor.ExtractTextFromImage(ctx, image)
+		schedule, moveByDate, err := s.snipe(ctx, text, &submission)
+		if err == nil {
+			return s.propose(ctx, phoneNumber, channel, schedule, moveByDate, location, submission, content, i+1)
+		}
+		firstErr = cmp.Or(firstErr, err)
+	}
+
+	// the schedule is sometimes split across a close-up and a wide shot
+	if len(texts) > 1 {
+		schedule, moveByDate, err := s.snipe(ctx, strings.Join(texts, "\n"), &submission)
+		if err == nil {
+			return s.propose(ctx, phoneNumber, channel, schedule, moveByDate, location, submission, content, 0)
+		}
+	}
+
+	submission.Error = firstErr.Error()
+	s.archive(ctx, submission, content)
+
+	s.logger.Error(ctx, errCreatingParkn, firstErr)
+	return Reading{}, errs.WrapError(errCreatingParkn, firstErr)
+}
+
+// CreateParknFromSchedule reads a typed schedule and holds it for the user to confirm. A typed
+// schedule also replaces any reading still waiting for confirmation, which is how users correct one.
+func (s *ParknService) CreateParknFromSchedule(ctx context.Context, phoneNumber, channel, text string, shared SharedLocation) (Reading, error) {
+
+	submission := model.SubmissionArchive{
+		PhoneNumber:   phoneNumber,
+		ExtractedText: text,
+	}
+
+	schedule, moveByDate, err := s.snipe(ctx, text, &submission)
 	if err != nil {
+		submission.Error = err.Error()
+		s.archive(ctx, submission, nil)
 		s.logger.Error(ctx, errCreatingParkn, err)
-		return "", errs.WrapError(errCreatingParkn, err)
+		return Reading{}, errs.WrapError(errCreatingParkn, err)
+	}
+
+	location := s.locate(ctx, shared)
+	return s.propose(ctx, phoneNumber, channel, schedule, moveByDate, location, submission, nil, 0)
+}
+
+// ConfirmParkn saves the reading waiting for confirmation and returns the endDate
+func (s *ParknService) ConfirmParkn(ctx context.Context, phoneNumber string) (time.Time, error) {
+
+	filter := bson.D{
+		{Key: "phoneNumber", Value: phoneNumber},
+		{Key: "expiresAt", Value: bson.D{
+			{Key: "$gt", Value: primitive.NewDateTimeFromTime(time.Now())},
+		}},
 	}
 
-	moveByDate, err := s.sniper.SnipeDate(ctx, extractedText)
+	// taking the pending reading atomically keeps a repeated YES from saving it twice
+	pending, found, err := s.pending.FindOneAndDelete(ctx, filter)
 	if err != nil {
-		s.logger.Error(ctx, errCreatingParkn, err)
-		return "", errs.WrapError(errCreatingParkn, err)
+		s.logger.Error(ctx, errConfirmingParkn, err)
+		return time.Time{}, errs.WrapError(errConfirmingParkn, err)
+	}
+	if !found {
+		return time.Time{}, ErrNothingToConfirm
 	}
 
 	parknInput := model.Parkn{
 		PhoneNumber: phoneNumber,
-		MoveByDate:  moveByDate,
+		Channel:     pending.Channel,
+		MoveByDate:  pending.MoveByDate,
+		Schedule:    pending.Schedule,
+		Location:    pending.Location,
+		Status:      model.ParknStatusPending,
 	}
 
 	id, err := s.repository.CreateOne(ctx, parknInput)
+	if err != nil {
+		s.logger.Error(ctx, errConfirmingParkn, err)
+		return time.Time{}, errs.WrapError(errConfirmingParkn, err)
+	}
+
+	if len(pending.ArchiveID) > 0 {
+		err = s.archiver.LinkParkn(ctx, pending.ArchiveID, id)
+		if err != nil {
+			s.logger.Error(ctx, errArchiveSubmission, err, "archiveId", pending.ArchiveID)
+		}
+	}
+
+	s.logger.Info(ctx, msgCreateParknSuccess, "id", id, "alertDate", pending.MoveByDate.Format(time.DateOnly))
+
+	if pending.Location != nil {
+		s.retireMoved(ctx, phoneNumber, id, *pending.Location)
+	}
+
+	return pending.MoveByDate, nil
+}
+
+// retireMoved cancels the repeating alerts saved before the one with keepID whose location isn't near where the
+// car is parked now, since it has moved away from them. Alerts without a location are kept. Failures are logged
+// and never fail the confirmation.
+func (s *ParknService) retireMoved(ctx context.Context, phoneNumber, keepID string, location model.Location) {
+
+	parkns, err := s.repository.Get(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		s.logger.Error(ctx, errRetireParkns, err, "phoneNumber", phoneNumber)
+		return
+	}
+
+	moved := make([]primitive.ObjectID, 0)
+	for _, parkn := range parkns {
+		if parkn.ID.Hex() == keepID || parkn.Location == nil || parkn.Location.Near(location) {
+			continue
+		}
+		moved = append(moved, parkn.ID)
+	}
+	if len(moved) == 0 {
+		return
+	}
+
+	_, err = s.repository.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: moved}}}})
+	if err != nil {
+		s.logger.Error(ctx, errRetireParkns, err, "phoneNumber", phoneNumber)
+		return
+	}
+	for _, id := range moved {
+		s.logger.Info(ctx, msgCarMoved, "id", id.Hex())
+	}
+}
+
+// CorrectParkn replaces the schedule of the most recently saved alert with a typed correction and keeps
+// the text it was originally read from alongside the correction so misreads can be studied
+func (s *ParknService) CorrectParkn(ctx context.Context, phoneNumber, text string) (Reading, error) {
+
+	schedule, moveByDate, err := s.sniper.SnipeDate(ctx, text)
+	if err != nil {
+		return Reading{}, errs.WrapError(errCorrectingParkn, err)
+	}
+
+	latest, found, err := s.latestParkn(ctx, phoneNumber)
+	if err != nil {
+		s.logger.Error(ctx, errCorrectingParkn, err)
+		return Reading{}, errs.WrapError(errCorrectingParkn, err)
+	}
+	if !found {
+		return Reading{}, ErrNothingToCorrect
+	}
+
+	// the lifecycle and reminders so far were for the old date
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "schedule", Value: schedule},
+			{Key: "moveByDate", Value: moveByDate},
+			{Key: "status", Value: model.ParknStatusPending},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "reminded", Value: ""}}},
+	}
+	_, err = s.repository.UpdateOne(ctx, bson.D{{Key: "_id", Value: latest.ID}}, update)
+	if err != nil {
+		s.logger.Error(ctx, errCorrectingParkn, err)
+		return Reading{}, errs.WrapError(errCorrectingParkn, err)
+	}
+
+	s.storeCorrection(ctx, latest, text, schedule)
+
+	reading := Reading{
+		Schedule: schedule,
+		NextOn:   moveByDate,
+	}
+
+	s.logger.Info(ctx, msgCorrectParknSuccess, "id", latest.ID.Hex(), "schedule", schedule.String())
+	return reading, nil
+}
+
+// SaveLocation attaches a location shared on its own, as iOS vCards and WhatsApp location messages are,
+// to the reading waiting for confirmation or else the most recently saved alert. It returns the street.
+// When that alert already has a location somewhere else the car has moved, so the alert's sweeps no longer
+// apply: it is canceled and ErrCarMoved is returned along with the street it was for.
+func (s *ParknService) SaveLocation(ctx context.Context, phoneNumber string, shared SharedLocation) (string, error) {
+
+	location := s.locate(ctx, shared)
+	if location == nil {
+		return "", ErrNoLocation
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "location", Value: location}}}}
+
+	pendingFilter := bson.D{
+		{Key: "phoneNumber", Value: phoneNumber},
+		{Key: "expiresAt", Value: bson.D{
+			{Key: "$gt", Value: primitive.NewDateTimeFromTime(time.Now())},
+		}},
+	}
+	modified, err := s.pending.UpdateOne(ctx, pendingFilter, update)
+	if err != nil {
+		s.logger.Error(ctx, errSavingLocation, err)
+		return "", errs.WrapError(errSavingLocation, err)
+	}
+	if modified > 0 {
+		return location.Street, nil
+	}
+
+	latest, found, err := s.latestParkn(ctx, phoneNumber)
+	if err != nil {
+		s.logger.Error(ctx, errSavingLocation, err)
+		return "", errs.WrapError(errSavingLocation, err)
+	}
+	if !found {
+		return "", ErrNothingToLocate
+	}
+
+	if latest.Location != nil && !latest.Location.Near(*location) {
+		_, err = s.repository.DeleteOne(ctx, bson.D{{Key: "_id", Value: latest.ID}})
+		if err != nil {
+			s.logger.Error(ctx, errSavingLocation, err)
+			return "", errs.WrapError(errSavingLocation, err)
+		}
+		s.logger.Info(ctx, msgCarMoved, "id", latest.ID.Hex())
+		return latest.Location.Street, ErrCarMoved
+	}
+
+	_, err = s.repository.UpdateOne(ctx, bson.D{{Key: "_id", Value: latest.ID}}, update)
+	if err != nil {
+		s.logger.Error(ctx, errSavingLocation, err)
+		return "", errs.WrapError(errSavingLocation, err)
+	}
+
+	return location.Street, nil
+}
+
+// NameParkn sets the nickname of the most recently saved alert, so alerts for several cars can be told apart
+func (s *ParknService) NameParkn(ctx context.Context, phoneNumber, nickname string) error {
+
+	latest, found, err := s.latestParkn(ctx, phoneNumber)
+	if err != nil {
+		return errs.WrapError(errNamingParkn, err)
+	}
+	if !found {
+		return ErrNothingToName
+	}
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "nickname", Value: nickname}}}}
+	_, err = s.repository.UpdateOne(ctx, bson.D{{Key: "_id", Value: latest.ID}}, update)
+	if err != nil {
+		return errs.WrapError(errNamingParkn, err)
+	}
+
+	s.logger.Info(ctx, msgNameParknSuccess, "id", latest.ID.Hex())
+	return nil
+}
+
+// AcknowledgeParkn moves the parkn an alert was for to acknowledged once the user replied MOVED, which stops the
+// reminders still to come for its sweep. A parkn that has since expired or moved to its next sweep is left alone.
+func (s *ParknService) AcknowledgeParkn(ctx context.Context, parkn model.Parkn) error {
+
+	filter := bson.D{
+		{Key: "_id", Value: parkn.ID},
+		{Key: "moveByDate", Value: parkn.MoveByDate},
+		{Key: "status", Value: bson.D{{Key: "$nin", Value: finishedStatuses}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: model.ParknStatusAcknowledged}}}}
+
+	modified, err := s.repository.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errs.WrapError(errAcknowledgeParkn, err)
+	}
+	if modified > 0 {
+		s.logger.Info(ctx, msgParknAcknowledged, "id", parkn.ID.Hex())
+	}
+	return nil
+}
+
+// latestParkn returns the most recently saved alert for a phone number
+func (s *ParknService) latestParkn(ctx context.Context, phoneNumber string) (model.Parkn, bool, error) {
+
+	parkns, err := s.repository.Get(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		return model.Parkn{}, false, err
+	}
+	if len(parkns) == 0 {
+		return model.Parkn{}, false, nil
+	}
+
+	// object ids start with their creation time, so the greatest one is the latest alert
+	latest := parkns[0]
+	for _, parkn := range parkns[1:] {
+		if parkn.ID.Hex() > latest.ID.Hex() {
+			latest = parkn
+		}
+	}
+
+	return latest, true, nil
+}
+
+// storeCorrection records the original text and the correction as a labeled pair. Failures are logged and never fail the request.
+func (s *ParknService) storeCorrection(ctx context.Context, parkn model.Parkn, text string, schedule model.Schedule) {
+
+	correction := model.Correction{
+		PhoneNumber:       parkn.PhoneNumber,
+		ParknID:           parkn.ID.Hex(),
+		OriginalSchedule:  parkn.Schedule,
+		CorrectionText:    text,
+		CorrectedSchedule: schedule,
+		CreatedAt:         time.Now(),
+	}
+
+	archive, err := s.archiver.GetByParknID(ctx, correction.ParknID)
+	if err != nil && !errors.Is(err, ErrArchiveNotFound) {
+		s.logger.Error(ctx, errStoreCorrection, err, "parknId", correction.ParknID)
+	}
+	correction.ExtractedText = archive.ExtractedText
+	correction.Phrase = s.sniper.MatchedPhrase(archive.ExtractedText)
+
+	_, err = s.corrections.CreateOne(ctx, correction)
+	if err != nil {
+		s.logger.Error(ctx, errStoreCorrection, err, "parknId", correction.ParknID)
+	}
+}
+
+// readMedia downloads and OCRs one attachment, recording what it found on the archived media
+func (s *ParknService) readMedia(ctx context.Context, mediaUrl string, archived *model.ArchivedMedia, content *[]byte) (string, error) {
+
+	media, err := s.httpClient.FetchMedia(ctx, mediaUrl)
+	if err != nil {
+		return "", err
+	}
+	archived.ContentType = media.ContentType
+	*content = media.Content
+
+	// hopeless photos are rejected locally so they never cost an OCR call
+	err = s.quality.CheckQuality(ctx, media.Image.Content)
+	if err != nil {
+		return "", err
+	}
+
+	extractedText, err := s.textExtractor.ExtractTextFromImage(ctx, media.Image)
+	if err != nil {
+		return "", err
+	}
+	archived.ExtractedText = extractedText
+
+	return extractedText, nil
+}
+
+func (s *ParknService) snipe(ctx context.Context, text string, submission *model.SubmissionArchive) (model.Schedule, time.Time, error) {
+
+	schedule, moveByDate, err := s.sniper.SnipeDate(ctx, text)
+	if err != nil {
+		return model.Schedule{}, time.Time{}, err
+	}
+	submission.ExtractedText = text
+	submission.MoveByDate = moveByDate
+
+	return schedule, moveByDate, nil
+}
+
+// propose archives the submission and holds the reading until the user confirms it or it expires
+func (s *ParknService) propose(ctx context.Context, phoneNumber, channel string, schedule model.Schedule, moveByDate time.Time, location *model.Location, submission model.SubmissionArchive, content [][]byte, photo int) (Reading, error) {
+
+	pending := model.PendingParkn{
+		PhoneNumber: phoneNumber,
+		Channel:     channel,
+		Schedule:    schedule,
+		MoveByDate:  moveByDate,
+		Location:    location,
+		ArchiveID:   s.archive(ctx, submission, content),
+		ExpiresAt:   time.Now().Add(s.pendingTTL),
+	}
+
+	err := s.pending.UpsertOne(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}}, pending)
 	if err != nil {
 		s.logger.Error(ctx, errCreatingParkn, err)
-		return "", errs.WrapError(errCreatingParkn, err)
+		return Reading{}, errs.WrapError(errCreatingParkn, err)
 	}
 
-	alertDate := fmtToString(moveByDate)
-	s.logger.Info(ctx, msgCreateParknSuccess, "id", id, "alertDate", alertDate)
+	reading := Reading{
+		Schedule: schedule,
+		NextOn:   moveByDate,
+		Photo:    photo,
+	}
+	if location != nil {
+		reading.Street = location.Street
+	}
+
+	s.logger.Info(ctx, msgPendingParkn, "phoneNumber", phoneNumber, "schedule", schedule.String())
+	return reading, nil
+}
+
+// locate finds the car's location in the message text or its vCards. vCards that fail to download are skipped.
+func (s *ParknService) locate(ctx context.Context, shared SharedLocation) *model.Location {
+
+	if len(shared.Latitude) > 0 && len(shared.Longitude) > 0 {
+		return s.locator.LocateCoordinates(ctx, shared.Latitude, shared.Longitude, shared.Address)
+	}
+
+	vCards := make([][]byte, 0, len(shared.VCardUrls))
+	for _, vCardUrl := range shared.VCardUrls {
+		content, _, err := s.httpClient.FetchFile(ctx, vCardUrl)
+		if err != nil {
+			s.logger.Error(ctx, errFetchVCard, err)
+			continue
+		}
+		vCards = append(vCards, content)
+	}
 
-	return alertDate, nil
+	return s.locator.Locate(ctx, shared.Text, vCards)
 }
 
-func fmtToString(t time.Time) string {
-	return t.Format("01-02-2006")
+// archive keeps a record of the submission for disputes and returns its id. Failures are logged and never fail the request.
+func (s *ParknService) archive(ctx context.Context, submission model.SubmissionArchive, media [][]byte) string {
+	id, err := s.archiver.Archive(ctx, submission, media)
+	if err != nil {
+		s.logger.Error(ctx, errArchiveSubmission, err, "phoneNumber", submission.PhoneNumber)
+	}
+	return id
+}
+
+// ListParkns returns the upcoming alerts for a phone number, soonest first
+func (s *ParknService) ListParkns(ctx context.Context, phoneNumber string) ([]model.Parkn, error) {
+
+	parkns, err := s.upcomingParkns(ctx, phoneNumber)
+	if err != nil {
+		return nil, errs.WrapError(errListingParkns, err)
+	}
+
+	return parkns, nil
+}
+
+// CancelParkns deletes the alert at the 1-based position returned by ListParkns, or every alert
+// for the phone number when index is zero. It returns the number of alerts canceled.
+func (s *ParknService) CancelParkns(ctx context.Context, phoneNumber string, index int) (int64, error) {
+
+	if index == 0 {
+		deleted, err := s.repository.DeleteMany(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+		if err != nil {
+			return 0, errs.WrapError(errCancelingParkn, err)
+		}
+		s.logger.Info(ctx, msgCancelParknSuccess, "phoneNumber", phoneNumber, "count", strconv.FormatInt(deleted, 10))
+		return deleted, nil
+	}
+
+	parkns, err := s.upcomingParkns(ctx, phoneNumber)
+	if err != nil {
+		return 0, errs.WrapError(errCancelingParkn, err)
+	}
+	if index < 0 || index > len(parkns) {
+		return 0, errs.WrapError(errCancelingParkn, ErrInvalidIndex)
+	}
+
+	deleted, err := s.repository.DeleteOne(ctx, bson.D{{Key: "_id", Value: parkns[index-1].ID}})
+	if err != nil {
+		return 0, errs.WrapError(errCancelingParkn, err)
+	}
+
+	s.logger.Info(ctx, msgCancelParknSuccess, "phoneNumber", phoneNumber, "count", strconv.FormatInt(deleted, 10))
+	return deleted, nil
+}
+
+func (s *ParknService) upcomingParkns(ctx context.Context, phoneNumber string) ([]model.Parkn, error) {
+
+	parkns, err := s.repository.Get(ctx, bson.D{{Key: "phoneNumber", Value: phoneNumber}})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(parkns, func(i, j int) bool {
+		return parkns[i].MoveByDate.Before(parkns[j].MoveByDate)
+	})
+
+	return parkns, nil
 }
@@ -12,6 +12,7 @@ import (
 	visionApi "cloud.google.com/go/vision/apiv1"
 	vision "cloud.google.com/go/vision/v2/apiv1/visionpb"
 	"github.com/willtowle1/parkn/internal/common/errs"
+	"github.com/willtowle1/parkn/internal/common/i18n"
 	"github.com/willtowle1/parkn/internal/common/logger"
 )
 
@@ -46,7 +47,7 @@ func (s *TextExtractor) ExtractTextFromImage(ctx context.Context, image *vision.
 		return "", errs.WrapError(errExtractingText, err)
 	}
 	if len(extractedText) == 0 {
-		return "", errs.WrapError(errExtractingText, errors.New(errNoTextExtracted))
+		return "", errs.WrapError(errExtractingText, NewAdvice(errNoTextExtracted, i18n.AdviceNoText))
 	}
 
 	return extractedText[0].Description, nil
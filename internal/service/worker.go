@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/willtowle1/parkn/internal/common/logger"
+)
+
+const (
+	errJobPanicked = "background job panicked"
+	errWorkerStop  = "background worker stopped before queued jobs finished"
+
+	msgWorkerStarted = "background worker started"
+	msgWorkerStopped = "background worker stopped"
+)
+
+type Job func(ctx context.Context)
+
+// Worker runs jobs on a fixed pool of goroutines fed by a bounded queue
+type Worker struct {
+	logger     logger.Logger
+	jobs       chan Job
+	numWorkers int
+	timeout    time.Duration
+	wg         sync.WaitGroup
+
+	// mu guards stopped so no job is sent on jobs once Stop has closed it
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func NewWorker(logger logger.Logger, numWorkers, queueSize int, timeout time.Duration) *Worker {
+	return &Worker{
+		logger:     logger,
+		jobs:       make(chan Job, queueSize),
+		numWorkers: numWorkers,
+		timeout:    timeout,
+	}
+}
+
+func (w *Worker) Start(ctx context.Context) {
+	for i := 0; i < w.numWorkers; i++ {
+		w.wg.Add(1)
+		go w.run(ctx)
+	}
+	w.logger.Info(ctx, msgWorkerStarted, "workers", fmt.Sprint(w.numWorkers))
+}
+
+// Submit queues a job and reports false without blocking when the queue is full or the worker has stopped
+func (w *Worker) Submit(job Job) bool {
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.stopped {
+		return false
+	}
+	select {
+	case w.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop waits for queued jobs to finish, or for ctx to be done. Jobs submitted afterwards are turned away.
+func (w *Worker) Stop(ctx context.Context) {
+
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.stopped = true
+	close(w.jobs)
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		w.logger.Info(ctx, msgWorkerStopped)
+	case <-ctx.Done():
+		w.logger.Error(ctx, errWorkerStop, ctx.Err())
+	}
+}
+
+func (w *Worker) run(ctx context.Context) {
+	defer w.wg.Done()
+	for job := range w.jobs {
+		w.runJob(ctx, job)
+	}
+}
+
+func (w *Worker) runJob(ctx context.Context, job Job) {
+
+	jobCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			w.logger.Error(ctx, errJobPanicked, fmt.Errorf("%v", r))
+		}
+	}()
+
+	job(jobCtx)
+}